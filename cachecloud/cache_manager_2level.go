@@ -2,30 +2,24 @@ package cachecloud
 
 import (
 	"context"
-	"encoding/hex"
 	"errors"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/acexy/golang-toolkit/caching"
-	"github.com/acexy/golang-toolkit/crypto/hashing"
 	"github.com/acexy/golang-toolkit/logger"
 	"github.com/acexy/golang-toolkit/util/coll"
-	"github.com/acexy/golang-toolkit/util/gob"
-	"github.com/golang-acexy/starter-redis/redisstarter"
-	"github.com/redis/go-redis/v9"
 )
 
 // 二级缓存：内存缓存作为一级 redis缓存为二级，如果内存缓存中没有发现会查看redis，如果redis存在会重建内存缓存
 // 由于重建的情况存在，所以内存缓存的过期时间在多个实例时可能不会一致，需要合理设计内存过期时间和redis的过期时间以及使用场景
 
 var level2Cache *secondLevelCacheManager
-var level2TopicCmd = redisstarter.TopicCmd()
 var level2TopicName = "2l-mem-sync-topic"
 
 // secondLevelCacheManager 二级缓存管理器
 type secondLevelCacheManager struct {
-	memCacheManager *caching.CacheManager
+	locals map[string]*localCacheBucket
 
 	configs            []CacheConfig
 	baseRedisKeyPrefix string
@@ -35,45 +29,17 @@ type secondLevelCacheManager struct {
 
 func initSecondLevelCacheManager(configs ...CacheConfig) {
 	if len(configs) > 0 {
-		manager := caching.NewEmptyCacheBucketManager()
-		for _, v := range configs {
-			manager.AddBucket(string(v.bucketName), caching.NewSimpleBigCache(v.memExpire))
-		}
+		locals := newLocalCacheBuckets(configs...)
 		if serviceNamePrefix != "" {
 			level2TopicName = serviceNamePrefix + ":" + level2TopicName
 		}
-		level2TopicCmd.SubscribeRetry(context.Background(), redisstarter.NewRedisKey(level2TopicName), func(v *redis.Message) {
-			if !strings.HasPrefix(v.Payload, getNodeId()) {
-				split := strings.SplitN(v.Payload, topicDelimiter, 4)
-				bucketName := split[1]
-				cacheKey := split[2]
-				sum := split[3]
-				key := caching.NewNemCacheKey(cacheKey)
-				bucket := manager.GetBucket(bucketName)
-				if sum == "" {
-					err := bucket.Evict(key)
-					if err == nil {
-						logger.Logrus().Traceln("l2 cache deleted", bucketName, cacheKey)
-					}
-					return
-				}
-				bytes, e := bucket.GetBytes(key)
-				if e == nil {
-					md5Bytes := hashing.Md5Bytes(bytes)
-					currentSum := hex.EncodeToString(md5Bytes[:])
-					if sum != currentSum {
-						logger.Logrus().Traceln("l2 cache changed", bucketName, cacheKey)
-						_ = bucket.Evict(key)
-					}
-				}
-			}
-		})
+		subscribeSyncMessage("l2 cache", level2TopicName, locals)
 		var keyPrefix = "l2:"
 		if serviceNamePrefix != "" {
 			keyPrefix = serviceNamePrefix + ":" + keyPrefix
 		}
 		level2Cache = &secondLevelCacheManager{
-			memCacheManager:    manager,
+			locals:             locals,
 			configs:            configs,
 			baseRedisKeyPrefix: keyPrefix,
 			buckets:            make(map[string]*secondLevelCacheBucket),
@@ -88,67 +54,346 @@ func (s *secondLevelCacheManager) getBucket(bucketName BucketName) CacheBucket {
 	}
 	defer s.mutex.Unlock()
 	s.mutex.Lock()
-	manager := s.memCacheManager.GetBucket(name)
-	if manager == nil {
+	local := s.locals[name]
+	if local == nil {
 		return nil
 	}
 	config, _ := coll.SliceFilterFirstOne(s.configs, func(item CacheConfig) bool {
 		return item.bucketName == bucketName
 	})
+	keyPrefix := config.redisKeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = s.baseRedisKeyPrefix + string(bucketName) + ":"
+	}
+	redisBucket := newRedisCacheBucket(keyPrefix, config)
+	if redisBucket.toucher != nil {
+		// 内存缓存在redis缓存延长成功后再延长 不会晚于redis缓存过期
+		redisBucket.toucher.onExtend = func(rawKey string, ttl time.Duration) {
+			_ = local.extend(strings.TrimPrefix(rawKey, keyPrefix), 0, ttl)
+		}
+	}
 	s.buckets[name] = &secondLevelCacheBucket{
-		memBucket: manager,
-		redisBucket: &redisCacheBucket{
-			keyPrefix: s.baseRedisKeyPrefix + string(bucketName) + ":",
-			expire:    config.redisExpire,
-		},
-		bucketName: string(bucketName),
+		memBucket:   local,
+		redisBucket: redisBucket,
+		bucketName:  string(bucketName),
 	}
 	return s.buckets[name]
 }
 
 // memeCacheBucket 内存缓存桶
 type secondLevelCacheBucket struct {
-	memBucket   caching.CacheBucket
+	memBucket   *localCacheBucket
 	redisBucket *redisCacheBucket
 	bucketName  string
 }
 
-func (m *secondLevelCacheBucket) publicEvent(bucketName, rawCacheKey, dataSum string) {
-	err := level2TopicCmd.Publish(redisstarter.NewRedisKey(level2TopicName), getNodeId()+topicDelimiter+bucketName+topicDelimiter+rawCacheKey+topicDelimiter+dataSum)
+func (m *secondLevelCacheBucket) publicEvent(ctx context.Context, entries ...syncEntry) {
+	publicSyncEvent(ctx, level2TopicName, m.bucketName, m.memBucket, entries...)
+}
+
+func (m *secondLevelCacheBucket) statsCounter() *bucketStats {
+	return m.memBucket.stats
+}
+
+func (m *secondLevelCacheBucket) Get(key CacheKey, result any, keyAppend ...interface{}) error {
+	return m.GetWithContext(context.Background(), key, result, keyAppend...)
+}
+
+func (m *secondLevelCacheBucket) Put(key CacheKey, data any, keyAppend ...interface{}) error {
+	return m.PutWithContext(context.Background(), key, data, keyAppend...)
+}
+
+func (m *secondLevelCacheBucket) Evict(key CacheKey, keyAppend ...interface{}) error {
+	return m.EvictWithContext(context.Background(), key, keyAppend...)
+}
+
+func (m *secondLevelCacheBucket) GetWithContext(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpGet, m.memBucket.stats, rawKey)
+	_, tier, err := m.get(ctx, rawKey, result)
+	m.memBucket.stats.recordGet(tier, err, start)
+	endGetSpan(span, tier, err)
+	return err
+}
+
+// getStale 获取缓存数据并反序列化 同时返回数据是否已过时
+func (m *secondLevelCacheBucket) getStale(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpGet, m.memBucket.stats, rawKey)
+	staleAt, tier, err := m.get(ctx, rawKey, result)
+	m.memBucket.stats.recordGet(tier, err, start)
+	endGetSpan(span, tier, err)
 	if err != nil {
-		logger.Logrus().Warningln("event publish failed", rawCacheKey, err)
+		return false, err
 	}
+	return staleAt > 0 && time.Now().UnixNano() >= staleAt, nil
 }
-func (m *secondLevelCacheBucket) Get(key CacheKey, result any, keyAppend ...interface{}) error {
-	err := m.memBucket.Get(caching.NewNemCacheKey(key.KeyFormat), result, keyAppend...)
-	if errors.Is(err, caching.ErrCacheMiss) {
-		logger.Logrus().Traceln("mem cache missed", key.RawKeyString(keyAppend...), "check redis")
-		err = m.redisBucket.Get(key, result, keyAppend...)
+
+func (m *secondLevelCacheBucket) staleEnabled() bool {
+	return m.redisBucket.staleEnabled() || m.memBucket.syncGapAction() == SyncGapRevalidate
+}
+
+// get 获取缓存数据并反序列化 返回数据过时时间戳以及结果所在的缓存层 内存缓存未命中时通过redis重建
+func (m *secondLevelCacheBucket) get(ctx context.Context, rawKey string, result any) (int64, statsTier, error) {
+	bytes, staleAt, err := m.memBucket.getEntry(rawKey)
+	if err == nil {
+		if err = decodeValue(m.memBucket.codec, bytes, result); err == nil {
+			m.slide(rawKey)
+		}
+		return staleAt, tierLocal, err
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		return 0, tierLocal, err
+	}
+	m.memBucket.stats.recordTier(tierLocal, err)
+	logger.Logrus().Traceln("mem cache missed", rawKey, "check redis")
+	var ttl time.Duration
+	bytes, ttl, err = m.redisBucket.getBytesWithTTL(ctx, m.redisBucket.keyPrefix+rawKey)
+	if err != nil {
 		if errors.Is(err, ErrCacheMiss) {
-			logger.Logrus().Traceln("redis cache missed", key.RawKeyString(keyAppend...))
-		} else {
-			logger.Logrus().Traceln("redis rebuild cache", key.RawKeyString(keyAppend...))
-			_ = m.memBucket.Put(caching.NewNemCacheKey(key.KeyFormat), result, keyAppend...)
+			logger.Logrus().Traceln("redis cache missed", rawKey)
 		}
+		return 0, tierRedis, err
+	}
+	err = decodeValue(m.redisBucket.codec, bytes, result)
+	if err != nil && !errors.Is(err, ErrCachedNotFound) {
+		return 0, tierRedis, err
+	}
+	if err == nil {
+		m.slide(rawKey)
+	}
+	// 重建的内存缓存不会晚于redis缓存过期
+	logger.Logrus().Traceln("redis rebuild cache", rawKey)
+	staleAt = m.redisBucket.staleAt(ttl)
+	_ = m.memBucket.putEntry(rawKey, bytes, ttl, staleAt)
+	return staleAt, tierRedis, err
+}
+
+func (m *secondLevelCacheBucket) PutWithContext(ctx context.Context, key CacheKey, data any, keyAppend ...interface{}) error {
+	return m.PutWithExpire(ctx, key, data, 0, keyAppend...)
+}
+
+func (m *secondLevelCacheBucket) PutWithExpire(ctx context.Context, key CacheKey, data any, expire time.Duration, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpPut, m.memBucket.stats, rawKey)
+	err := m.put(ctx, rawKey, data, expire)
+	endSpan(span, SpanEnd{Err: err})
 	return err
 }
 
-func (m *secondLevelCacheBucket) Put(key CacheKey, data any, keyAppend ...interface{}) error {
-	_ = m.redisBucket.Put(key, data, keyAppend...)
-	err := m.memBucket.Put(caching.NewNemCacheKey(key.KeyFormat), data, keyAppend...)
+// put 序列化并设置缓存数据 redis写入失败时仍会设置内存缓存
+func (m *secondLevelCacheBucket) put(ctx context.Context, rawKey string, data any, expire time.Duration) error {
+	start := time.Now()
+	encode, err := m.redisBucket.codec.Encode(data)
+	if err != nil {
+		m.memBucket.stats.recordPut(0, err, start)
+		return err
+	}
+	if expire <= 0 {
+		expire = m.redisBucket.defaultExpire()
+	}
+	if err = m.redisBucket.putBytes(ctx, m.redisBucket.keyPrefix+rawKey, encode, expire); err != nil {
+		if ctx.Err() != nil {
+			m.memBucket.stats.recordPut(0, ctx.Err(), start)
+			return ctx.Err()
+		}
+		m.memBucket.stats.recordTierError(tierRedis, err)
+		logger.Logrus().Warningln("redis cache put failed", rawKey, err)
+	}
+	err = m.memBucket.putBytes(rawKey, encode, expire)
+	m.memBucket.stats.recordPut(successCount(err), err, start)
 	if err == nil {
 		// 同步缓存数据发生变化的事件
-		encode, _ := gob.Encode(data)
-		md5Bytes := hashing.Md5Bytes(encode)
-		m.publicEvent(m.bucketName, key.RawKeyString(keyAppend...), hex.EncodeToString(md5Bytes[:]))
+		m.publicEvent(ctx, newChangedSyncEntry(rawKey, encode, expire))
 	}
 	return err
 }
 
-func (m *secondLevelCacheBucket) Evict(key CacheKey, keyAppend ...interface{}) error {
-	_ = m.redisBucket.Evict(key, keyAppend...)
-	err := m.memBucket.Evict(caching.NewNemCacheKey(key.KeyFormat), keyAppend...)
-	m.publicEvent(m.bucketName, key.RawKeyString(keyAppend...), "")
+func (m *secondLevelCacheBucket) putNotFound(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	expire := m.redisBucket.negativeExpire
+	if expire <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rawKey := key.RawKeyString(keyAppend...)
+	if err := m.redisBucket.putBytes(ctx, m.redisBucket.keyPrefix+rawKey, notFoundMarker, expire); err != nil {
+		return err
+	}
+	err := m.memBucket.putBytes(rawKey, notFoundMarker, expire)
+	if err == nil {
+		m.publicEvent(ctx, newChangedSyncEntry(rawKey, notFoundMarker, expire))
+	}
+	return err
+}
+
+func (m *secondLevelCacheBucket) EvictWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpEvict, m.memBucket.stats, rawKey)
+	err := m.evict(ctx, rawKey)
+	endSpan(span, SpanEnd{Err: err})
+	return err
+}
+
+// evict 清除redis缓存与内存缓存 并同步至其它实例
+func (m *secondLevelCacheBucket) evict(ctx context.Context, rawKey string) error {
+	start := time.Now()
+	if err := m.redisBucket.del(ctx, m.redisBucket.keyPrefix+rawKey); err != nil && !errors.Is(err, ErrCacheMiss) {
+		if ctx.Err() != nil {
+			m.memBucket.stats.recordEvict(0, ctx.Err(), start)
+			return ctx.Err()
+		}
+		m.memBucket.stats.recordTierError(tierRedis, err)
+	}
+	err := m.memBucket.evict(rawKey)
+	m.memBucket.stats.recordEvict(1, err, start)
+	m.publicEvent(ctx, newDeletedSyncEntry(rawKey))
+	return err
+}
+
+func (m *secondLevelCacheBucket) MGet(ctx context.Context, keys []BatchKey, results []any) ([]bool, error) {
+	if err := checkBatchArgs(ctx, keys, results); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	hits := make([]bool, len(keys))
+	for i, key := range keys {
+		rawKey := key.RawKeyString()
+		bytes, err := m.memBucket.getBytes(rawKey)
+		if err == nil && decodeValue(m.memBucket.codec, bytes, results[i]) == nil {
+			hits[i] = true
+			m.slide(rawKey)
+		}
+	}
+	m.memBucket.stats.recordHits(tierLocal, hits)
+	// 仅对内存缓存未命中的key查询redis
+	missIndexes := make([]int, 0, len(keys))
+	missRawKeys := make([]string, 0, len(keys))
+	for i, hit := range hits {
+		if !hit {
+			missIndexes = append(missIndexes, i)
+			missRawKeys = append(missRawKeys, m.redisBucket.keyPrefix+keys[i].RawKeyString())
+		}
+	}
+	if len(missIndexes) == 0 {
+		m.memBucket.stats.recordMGet(hits, nil, start)
+		return hits, nil
+	}
+	logger.Logrus().Traceln("mem cache missed", len(missIndexes), "check redis")
+	values, ttls, err := mGetBytesWithTTL(ctx, missRawKeys)
+	if err != nil {
+		m.memBucket.stats.recordTierError(tierRedis, err)
+		m.memBucket.stats.recordMGet(hits, err, start)
+		return hits, err
+	}
+	redisHits := make([]bool, len(missIndexes))
+	for i, index := range missIndexes {
+		if values[i] == nil {
+			continue
+		}
+		redisHits[i] = true
+		staleAt := m.redisBucket.staleAt(ttls[i])
+		if isNotFoundMarker(values[i]) {
+			_ = m.memBucket.putEntry(keys[index].RawKeyString(), values[i], ttls[i], staleAt)
+			continue
+		}
+		if m.redisBucket.codec.Decode(values[i], results[index]) != nil {
+			continue
+		}
+		hits[index] = true
+		_ = m.memBucket.putEntry(keys[index].RawKeyString(), values[i], ttls[i], staleAt)
+		m.slide(keys[index].RawKeyString())
+	}
+	m.memBucket.stats.recordHits(tierRedis, redisHits)
+	m.memBucket.stats.recordMGet(hits, nil, start)
+	return hits, nil
+}
+
+func (m *secondLevelCacheBucket) MPut(ctx context.Context, keys []BatchKey, data []any) error {
+	if err := checkBatchArgs(ctx, keys, data); err != nil {
+		return err
+	}
+	start := time.Now()
+	values := make([][]byte, len(data))
+	for i, v := range data {
+		bytes, err := m.redisBucket.codec.Encode(v)
+		if err != nil {
+			m.memBucket.stats.recordPut(0, err, start)
+			return err
+		}
+		values[i] = bytes
+	}
+	expire := m.redisBucket.defaultExpire()
+	if err := mPutBytes(ctx, m.redisBucket.rawKeys(keys), values, expire); err != nil {
+		if ctx.Err() != nil {
+			m.memBucket.stats.recordPut(0, ctx.Err(), start)
+			return ctx.Err()
+		}
+		m.memBucket.stats.recordTierError(tierRedis, err)
+		logger.Logrus().Warningln("redis cache batch put failed", len(keys), err)
+	}
+	entries := make([]syncEntry, 0, len(keys))
+	var lastErr error
+	for i, key := range keys {
+		rawKey := key.RawKeyString()
+		if err := m.memBucket.putBytes(rawKey, values[i], expire); err != nil {
+			lastErr = err
+			continue
+		}
+		entries = append(entries, newChangedSyncEntry(rawKey, values[i], expire))
+	}
+	m.memBucket.stats.recordPut(len(entries), lastErr, start)
+	// 批量变化合并为一条同步消息
+	m.publicEvent(ctx, entries...)
+	return lastErr
+}
+
+func (m *secondLevelCacheBucket) MEvict(ctx context.Context, keys []BatchKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := mDel(ctx, m.redisBucket.rawKeys(keys)); err != nil {
+		if ctx.Err() != nil {
+			m.memBucket.stats.recordEvict(0, ctx.Err(), start)
+			return ctx.Err()
+		}
+		m.memBucket.stats.recordTierError(tierRedis, err)
+	}
+	entries := make([]syncEntry, len(keys))
+	for i, key := range keys {
+		_ = m.memBucket.evict(key.RawKeyString())
+		entries[i] = newDeletedSyncEntry(key.RawKeyString())
+	}
+	m.memBucket.stats.recordEvict(len(keys), nil, start)
+	m.publicEvent(ctx, entries...)
+	return nil
+}
+
+func (m *secondLevelCacheBucket) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	redisErr := clearPrefix(ctx, m.redisBucket.keyPrefix)
+	err := m.memBucket.clear()
+	m.publicEvent(ctx, newFlushSyncEntry())
+	if redisErr != nil {
+		return redisErr
+	}
 	return err
 }
@@ -2,69 +2,31 @@ package cachecloud
 
 import (
 	"context"
-	"encoding/hex"
-	"errors"
-	"strings"
 	"sync"
-
-	"github.com/acexy/golang-toolkit/caching"
-	"github.com/acexy/golang-toolkit/crypto/hashing"
-	"github.com/acexy/golang-toolkit/logger"
-	"github.com/acexy/golang-toolkit/util/gob"
-	"github.com/golang-acexy/starter-redis/redisstarter"
-	"github.com/redis/go-redis/v9"
+	"time"
 )
 
 // 分布式内存缓存：内存缓存的同步只使用失效过期同步(及某个实例触发失效时，向其它实例同步实现信息清除该缓存)，并不保持持续同步。
 
 var distMemCache *distMemCacheManager
-var distMemTopicCmd = redisstarter.TopicCmd()
 var distMemTopicName = "dis-mem-sync-topic"
 
 // memCacheManager 内存缓存管理器
 type distMemCacheManager struct {
-	manager *caching.CacheManager
+	locals  map[string]*localCacheBucket
 	buckets map[string]*distMemeCacheBucket
 	blocker sync.Mutex
 }
 
 func initDistMemCacheManager(configs ...CacheConfig) {
 	if len(configs) > 0 {
-		manager := caching.NewEmptyCacheBucketManager()
-		for _, v := range configs {
-			manager.AddBucket(string(v.bucketName), caching.NewSimpleBigCache(v.memExpire))
-		}
+		locals := newLocalCacheBuckets(configs...)
 		if serviceNamePrefix != "" {
 			distMemTopicName = serviceNamePrefix + ":" + distMemTopicName
 		}
-		distMemTopicCmd.SubscribeRetry(context.Background(), redisstarter.NewRedisKey(distMemTopicName), func(v *redis.Message) {
-			if !strings.HasPrefix(v.Payload, getNodeId()) {
-				split := strings.SplitN(v.Payload, topicDelimiter, 4)
-				bucketName := split[1]
-				cacheKey := split[2]
-				sum := split[3]
-				key := caching.NewNemCacheKey(cacheKey)
-				bucket := manager.GetBucket(bucketName)
-				if sum == "" {
-					err := bucket.Evict(key)
-					if err == nil {
-						logger.Logrus().Traceln("dist mem cache deleted", bucketName, cacheKey)
-					}
-					return
-				}
-				bytes, e := bucket.GetBytes(key)
-				if e == nil {
-					md5Bytes := hashing.Md5Bytes(bytes)
-					currentSum := hex.EncodeToString(md5Bytes[:])
-					if sum != currentSum {
-						logger.Logrus().Traceln("dist mem cache changed", bucketName, cacheKey)
-						_ = bucket.Evict(key)
-					}
-				}
-			}
-		})
+		subscribeSyncMessage("dist mem cache", distMemTopicName, locals)
 		distMemCache = &distMemCacheManager{
-			manager: manager,
+			locals:  locals,
 			buckets: make(map[string]*distMemeCacheBucket),
 		}
 	}
@@ -75,13 +37,13 @@ func (m *distMemCacheManager) getBucket(bucketName BucketName) CacheBucket {
 	if bucket, ok := m.buckets[name]; ok {
 		return bucket
 	}
-	if m.manager.GetBucket(name) == nil {
+	if m.locals[name] == nil {
 		return nil
 	}
 	defer m.blocker.Unlock()
 	m.blocker.Lock()
 	m.buckets[name] = &distMemeCacheBucket{
-		bucket:     m.manager.GetBucket(name),
+		bucket:     m.locals[name],
 		bucketName: name,
 	}
 	return m.buckets[name]
@@ -89,40 +51,169 @@ func (m *distMemCacheManager) getBucket(bucketName BucketName) CacheBucket {
 
 // memeCacheBucket 内存缓存桶
 type distMemeCacheBucket struct {
-	bucket     caching.CacheBucket
+	bucket     *localCacheBucket
 	bucketName string
 }
 
-func (m *distMemeCacheBucket) publicEvent(bucketName, rawCacheKey, dataSum string) {
-	err := distMemTopicCmd.Publish(redisstarter.NewRedisKey(distMemTopicName), getNodeId()+topicDelimiter+bucketName+topicDelimiter+rawCacheKey+topicDelimiter+dataSum)
-	if err != nil {
-		logger.Logrus().Warningln("event publish failed", rawCacheKey, err)
-	}
+func (m *distMemeCacheBucket) publicEvent(ctx context.Context, entries ...syncEntry) {
+	publicSyncEvent(ctx, distMemTopicName, m.bucketName, m.bucket, entries...)
 }
+
+func (m *distMemeCacheBucket) statsCounter() *bucketStats {
+	return m.bucket.stats
+}
+
 func (m *distMemeCacheBucket) Get(key CacheKey, result any, keyAppend ...interface{}) error {
-	err := m.bucket.Get(caching.NewNemCacheKey(key.KeyFormat), result, keyAppend...)
-	if errors.Is(err, caching.ErrCacheMiss) {
-		err = ErrCacheMiss
+	return m.GetWithContext(context.Background(), key, result, keyAppend...)
+}
+
+func (m *distMemeCacheBucket) Put(key CacheKey, data any, keyAppend ...interface{}) error {
+	return m.PutWithContext(context.Background(), key, data, keyAppend...)
+}
+
+func (m *distMemeCacheBucket) Evict(key CacheKey, keyAppend ...interface{}) error {
+	return m.EvictWithContext(context.Background(), key, keyAppend...)
+}
+
+func (m *distMemeCacheBucket) GetWithContext(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	_, span := startSpan(ctx, TraceOpGet, m.bucket.stats, rawKey)
+	err := m.bucket.get(rawKey, result)
+	m.bucket.stats.recordGet(tierLocal, err, start)
+	endGetSpan(span, tierLocal, err)
 	return err
 }
 
-func (m *distMemeCacheBucket) Put(key CacheKey, data any, keyAppend ...interface{}) error {
-	err := m.bucket.Put(caching.NewNemCacheKey(key.KeyFormat), data, keyAppend...)
+func (m *distMemeCacheBucket) getStale(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	_, span := startSpan(ctx, TraceOpGet, m.bucket.stats, rawKey)
+	stale, err := m.bucket.getStale(rawKey, result)
+	m.bucket.stats.recordGet(tierLocal, err, start)
+	endGetSpan(span, tierLocal, err)
+	return stale, err
+}
+
+func (m *distMemeCacheBucket) staleEnabled() bool {
+	return m.bucket.softExpire > 0 || m.bucket.syncGapAction() == SyncGapRevalidate
+}
+
+func (m *distMemeCacheBucket) PutWithContext(ctx context.Context, key CacheKey, data any, keyAppend ...interface{}) error {
+	return m.PutWithExpire(ctx, key, data, 0, keyAppend...)
+}
+
+func (m *distMemeCacheBucket) PutWithExpire(ctx context.Context, key CacheKey, data any, expire time.Duration, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpPut, m.bucket.stats, rawKey)
+	encode, err := m.bucket.codec.Encode(data)
+	if err == nil {
+		err = m.bucket.putBytes(rawKey, encode, expire)
+	}
+	m.bucket.stats.recordPut(successCount(err), err, start)
 	if err == nil {
 		// 同步缓存数据发生变化的事件
-		encode, _ := gob.Encode(data)
-		md5Array := hashing.Md5Bytes(encode)
-		m.publicEvent(m.bucketName, key.RawKeyString(keyAppend...), hex.EncodeToString(md5Array[:]))
+		m.publicEvent(ctx, newChangedSyncEntry(rawKey, encode, expire))
 	}
+	endSpan(span, SpanEnd{Err: err})
 	return err
 }
 
-func (m *distMemeCacheBucket) Evict(key CacheKey, keyAppend ...interface{}) error {
-	err := m.bucket.Evict(caching.NewNemCacheKey(key.KeyFormat), keyAppend...)
-	if err != nil {
-		// 同步缓存数据删除事件
-		m.publicEvent(m.bucketName, key.RawKeyString(keyAppend...), "")
+func (m *distMemeCacheBucket) putNotFound(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	if m.bucket.negativeExpire <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rawKey := key.RawKeyString(keyAppend...)
+	err := m.bucket.putBytes(rawKey, notFoundMarker, m.bucket.negativeExpire)
+	if err == nil {
+		m.publicEvent(ctx, newChangedSyncEntry(rawKey, notFoundMarker, m.bucket.negativeExpire))
+	}
+	return err
+}
+
+func (m *distMemeCacheBucket) EvictWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpEvict, m.bucket.stats, rawKey)
+	err := m.bucket.evict(rawKey)
+	m.bucket.stats.recordEvict(1, err, start)
+	// 同步缓存数据删除事件 本地未命中时其它实例仍可能持有该缓存
+	m.publicEvent(ctx, newDeletedSyncEntry(rawKey))
+	endSpan(span, SpanEnd{Err: err})
+	return err
+}
+
+func (m *distMemeCacheBucket) MGet(ctx context.Context, keys []BatchKey, results []any) ([]bool, error) {
+	if err := checkBatchArgs(ctx, keys, results); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	hits := m.bucket.mGet(keys, results)
+	m.bucket.stats.recordHits(tierLocal, hits)
+	m.bucket.stats.recordMGet(hits, nil, start)
+	return hits, nil
+}
+
+func (m *distMemeCacheBucket) MPut(ctx context.Context, keys []BatchKey, data []any) error {
+	if err := checkBatchArgs(ctx, keys, data); err != nil {
+		return err
+	}
+	start := time.Now()
+	entries := make([]syncEntry, 0, len(keys))
+	var lastErr error
+	for i, key := range keys {
+		encode, err := m.bucket.codec.Encode(data[i])
+		if err == nil {
+			err = m.bucket.putBytes(key.RawKeyString(), encode, 0)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		entries = append(entries, newChangedSyncEntry(key.RawKeyString(), encode, 0))
+	}
+	m.bucket.stats.recordPut(len(entries), lastErr, start)
+	// 批量变化合并为一条同步消息
+	m.publicEvent(ctx, entries...)
+	return lastErr
+}
+
+func (m *distMemeCacheBucket) MEvict(ctx context.Context, keys []BatchKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	entries := make([]syncEntry, len(keys))
+	for i, key := range keys {
+		_ = m.bucket.evict(key.RawKeyString())
+		entries[i] = newDeletedSyncEntry(key.RawKeyString())
+	}
+	m.bucket.stats.recordEvict(len(keys), nil, start)
+	m.publicEvent(ctx, entries...)
+	return nil
+}
+
+func (m *distMemeCacheBucket) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	err := m.bucket.clear()
+	m.publicEvent(ctx, newFlushSyncEntry())
 	return err
 }
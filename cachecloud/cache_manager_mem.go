@@ -1,29 +1,24 @@
 package cachecloud
 
 import (
-	"errors"
+	"context"
 	"sync"
-
-	"github.com/acexy/golang-toolkit/caching"
+	"time"
 )
 
 var memCache *memCacheManager
 
 // memCacheManager 内存缓存管理器
 type memCacheManager struct {
-	manager *caching.CacheManager
+	locals  map[string]*localCacheBucket
 	buckets map[string]*memeCacheBucket
 	blocker sync.Mutex
 }
 
 func initMemCacheManager(configs ...CacheConfig) {
 	if len(configs) > 0 {
-		manager := caching.NewEmptyCacheBucketManager()
-		for _, v := range configs {
-			manager.AddBucket(string(v.bucketName), caching.NewSimpleBigCache(v.memExpire))
-		}
 		memCache = &memCacheManager{
-			manager: manager,
+			locals:  newLocalCacheBuckets(configs...),
 			buckets: make(map[string]*memeCacheBucket),
 		}
 	}
@@ -34,34 +29,152 @@ func (m *memCacheManager) getBucket(bucketName BucketName) CacheBucket {
 	if bucket, ok := m.buckets[name]; ok {
 		return bucket
 	}
-	if m.manager.GetBucket(name) == nil {
+	if m.locals[name] == nil {
 		return nil
 	}
 	defer m.blocker.Unlock()
 	m.blocker.Lock()
 	m.buckets[name] = &memeCacheBucket{
-		bucket: m.manager.GetBucket(name),
+		bucket: m.locals[name],
 	}
 	return m.buckets[name]
 }
 
 // memeCacheBucket 内存缓存桶
 type memeCacheBucket struct {
-	bucket caching.CacheBucket
+	bucket *localCacheBucket
 }
 
 func (m *memeCacheBucket) Get(key CacheKey, result any, keyAppend ...interface{}) error {
-	err := m.bucket.Get(caching.NewNemCacheKey(key.KeyFormat), result, keyAppend...)
-	if errors.Is(err, caching.ErrCacheMiss) {
-		err = ErrCacheMiss
-	}
-	return err
+	return m.GetWithContext(context.Background(), key, result, keyAppend...)
 }
 
 func (m *memeCacheBucket) Put(key CacheKey, data any, keyAppend ...interface{}) error {
-	return m.bucket.Put(caching.NewNemCacheKey(key.KeyFormat), data, keyAppend...)
+	return m.PutWithContext(context.Background(), key, data, keyAppend...)
 }
 
 func (m *memeCacheBucket) Evict(key CacheKey, keyAppend ...interface{}) error {
-	return m.bucket.Evict(caching.NewNemCacheKey(key.KeyFormat), keyAppend...)
+	return m.EvictWithContext(context.Background(), key, keyAppend...)
+}
+
+func (m *memeCacheBucket) GetWithContext(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	_, span := startSpan(ctx, TraceOpGet, m.bucket.stats, rawKey)
+	err := m.bucket.get(rawKey, result)
+	m.bucket.stats.recordGet(tierLocal, err, start)
+	endGetSpan(span, tierLocal, err)
+	return err
+}
+
+func (m *memeCacheBucket) getStale(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	_, span := startSpan(ctx, TraceOpGet, m.bucket.stats, rawKey)
+	stale, err := m.bucket.getStale(rawKey, result)
+	m.bucket.stats.recordGet(tierLocal, err, start)
+	endGetSpan(span, tierLocal, err)
+	return stale, err
+}
+
+func (m *memeCacheBucket) statsCounter() *bucketStats {
+	return m.bucket.stats
+}
+
+func (m *memeCacheBucket) staleEnabled() bool {
+	return m.bucket.softExpire > 0
+}
+
+func (m *memeCacheBucket) PutWithContext(ctx context.Context, key CacheKey, data any, keyAppend ...interface{}) error {
+	return m.PutWithExpire(ctx, key, data, 0, keyAppend...)
+}
+
+func (m *memeCacheBucket) PutWithExpire(ctx context.Context, key CacheKey, data any, expire time.Duration, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	_, span := startSpan(ctx, TraceOpPut, m.bucket.stats, rawKey)
+	err := m.bucket.put(rawKey, data, expire)
+	m.bucket.stats.recordPut(successCount(err), err, start)
+	endSpan(span, SpanEnd{Err: err})
+	return err
+}
+
+func (m *memeCacheBucket) putNotFound(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	if m.bucket.negativeExpire <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.bucket.putBytes(key.RawKeyString(keyAppend...), notFoundMarker, m.bucket.negativeExpire)
+}
+
+func (m *memeCacheBucket) EvictWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	_, span := startSpan(ctx, TraceOpEvict, m.bucket.stats, rawKey)
+	err := m.bucket.evict(rawKey)
+	m.bucket.stats.recordEvict(1, err, start)
+	endSpan(span, SpanEnd{Err: err})
+	return err
+}
+
+func (m *memeCacheBucket) MGet(ctx context.Context, keys []BatchKey, results []any) ([]bool, error) {
+	if err := checkBatchArgs(ctx, keys, results); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	hits := m.bucket.mGet(keys, results)
+	m.bucket.stats.recordHits(tierLocal, hits)
+	m.bucket.stats.recordMGet(hits, nil, start)
+	return hits, nil
+}
+
+func (m *memeCacheBucket) MPut(ctx context.Context, keys []BatchKey, data []any) error {
+	if err := checkBatchArgs(ctx, keys, data); err != nil {
+		return err
+	}
+	start := time.Now()
+	var lastErr error
+	count := 0
+	for i, key := range keys {
+		if err := m.bucket.put(key.RawKeyString(), data[i], 0); err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+	}
+	m.bucket.stats.recordPut(count, lastErr, start)
+	return lastErr
+}
+
+func (m *memeCacheBucket) MEvict(ctx context.Context, keys []BatchKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	for _, key := range keys {
+		_ = m.bucket.evict(key.RawKeyString())
+	}
+	m.bucket.stats.recordEvict(len(keys), nil, start)
+	return nil
+}
+
+func (m *memeCacheBucket) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.bucket.clear()
 }
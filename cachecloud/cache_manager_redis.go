@@ -1,7 +1,9 @@
 package cachecloud
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/acexy/golang-toolkit/util/coll"
@@ -22,45 +24,457 @@ func initRedisCacheManager(configs ...CacheConfig) {
 		redisCache = &redisCacheManager{
 			buckets: make(map[BucketName]*redisCacheBucket),
 		}
-		var keyPrefix string
-		if serviceNamePrefix != "" {
-			keyPrefix = serviceNamePrefix + ":"
-		}
 		coll.SliceForeachAll(configs, func(config CacheConfig) {
-			redisCache.buckets[config.bucketName] = &redisCacheBucket{
-				keyPrefix: keyPrefix + string(config.bucketName) + ":",
-				expire:    config.redisExpire,
-			}
+			redisCache.buckets[config.bucketName] = newRedisCacheBucket(redisBucketKeyPrefix(config.bucketName), config)
 		})
 	}
 }
 
+// redisBucketKeyPrefix redis存储桶的key前缀
+func redisBucketKeyPrefix(bucketName BucketName) string {
+	if serviceNamePrefix != "" {
+		return serviceNamePrefix + ":" + string(bucketName) + ":"
+	}
+	return string(bucketName) + ":"
+}
+
 func (m *redisCacheManager) getBucket(bucketName BucketName) CacheBucket {
-	return m.buckets[bucketName]
+	if bucket, ok := m.buckets[bucketName]; ok {
+		return bucket
+	}
+	return nil
 }
 
 // redisCacheBucket redis缓存桶
 type redisCacheBucket struct {
 	keyPrefix string
 	expire    time.Duration
+	codec     Codec
+
+	negativeExpire time.Duration
+	softExpire     time.Duration
+	hardExpire     time.Duration
+
+	toucher *redisToucher // 滑动过期 未启用时为空
+
+	stats *bucketStats
 }
 
-func (m *redisCacheBucket) Get(key CacheKey, result any, keyAppend ...interface{}) error {
-	err := redisstarter.StringCmd().GetAnyWithGob(redisstarter.NewRedisKey(m.keyPrefix+key.KeyFormat, m.expire), result, keyAppend...)
-	if errors.Is(err, redis.Nil) {
-		err = ErrCacheMiss
+func newRedisCacheBucket(keyPrefix string, config CacheConfig) *redisCacheBucket {
+	softExpire, hardExpire := config.staleExpire()
+	bucket := &redisCacheBucket{
+		keyPrefix:      keyPrefix,
+		expire:         config.redisExpire,
+		codec:          config.getCodec(),
+		negativeExpire: config.negativeExpire,
+		softExpire:     softExpire,
+		hardExpire:     hardExpire,
+		stats:          bucketStatsOf(config.bucketName, config.typ),
 	}
-	return err
+	if interval := config.slidingInterval(); interval > 0 && bucket.defaultExpire() > 0 {
+		bucket.toucher = newRedisToucher(interval, bucket.defaultExpire())
+	}
+	return bucket
+}
+
+// defaultExpire 未指定过期时间时写入缓存使用的过期时间
+func (m *redisCacheBucket) defaultExpire() time.Duration {
+	if m.hardExpire > 0 {
+		return m.hardExpire
+	}
+	return m.expire
+}
+
+// staleAt 根据剩余过期时间计算数据过时的时间戳 未启用过时数据后台刷新时返回零值
+// 数据以 hardExpire 写入，剩余过期时间小于 hardExpire-softExpire 即视为已过时
+func (m *redisCacheBucket) staleAt(ttl time.Duration) int64 {
+	if m.softExpire <= 0 || ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl - (m.hardExpire - m.softExpire)).UnixNano()
+}
+
+// rawKey 获取redis中实际存储的key
+func (m *redisCacheBucket) rawKey(key CacheKey, keyAppend ...interface{}) string {
+	return m.keyPrefix + key.RawKeyString(keyAppend...)
+}
+
+func (m *redisCacheBucket) Get(key CacheKey, result any, keyAppend ...interface{}) error {
+	return m.GetWithContext(context.Background(), key, result, keyAppend...)
 }
 
 func (m *redisCacheBucket) Put(key CacheKey, data any, keyAppend ...interface{}) error {
-	return redisstarter.StringCmd().SetAnyWithGob(redisstarter.NewRedisKey(m.keyPrefix+key.KeyFormat, m.expire), data, keyAppend...)
+	return m.PutWithContext(context.Background(), key, data, keyAppend...)
 }
 
 func (m *redisCacheBucket) Evict(key CacheKey, keyAppend ...interface{}) error {
-	result := redisstarter.KeyCmd().Del(redisstarter.NewRedisKey(m.keyPrefix+key.KeyFormat, m.expire), keyAppend...)
+	return m.EvictWithContext(context.Background(), key, keyAppend...)
+}
+
+func (m *redisCacheBucket) statsCounter() *bucketStats {
+	return m.stats
+}
+
+func (m *redisCacheBucket) GetWithContext(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) error {
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpGet, m.stats, rawKey)
+	err := m.get(ctx, m.keyPrefix+rawKey, result)
+	m.stats.recordGet(tierRedis, err, start)
+	endGetSpan(span, tierRedis, err)
+	return err
+}
+
+// get 获取缓存数据并反序列化
+func (m *redisCacheBucket) get(ctx context.Context, rawKey string, result any) error {
+	bytes, err := redisstarter.RawRedisClient().Get(ctx, rawKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrCacheMiss
+		}
+		return err
+	}
+	if err = decodeValue(m.codec, unwrapRedisTTL(bytes), result); err == nil {
+		m.slide(rawKey)
+	}
+	return err
+}
+
+// getStale 获取缓存数据并反序列化 同时返回数据是否已过时
+func (m *redisCacheBucket) getStale(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) (bool, error) {
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpGet, m.stats, rawKey)
+	bytes, ttl, err := m.getBytesWithTTL(ctx, m.keyPrefix+rawKey)
+	if err == nil {
+		err = decodeValue(m.codec, bytes, result)
+	}
+	m.stats.recordGet(tierRedis, err, start)
+	endGetSpan(span, tierRedis, err)
+	if err != nil {
+		return false, err
+	}
+	staleAt := m.staleAt(ttl)
+	return staleAt > 0 && time.Now().UnixNano() >= staleAt, nil
+}
+
+func (m *redisCacheBucket) staleEnabled() bool {
+	return m.softExpire > 0
+}
+
+// getBytesWithTTL 获取原始缓存数据以及剩余的过期时间
+func (m *redisCacheBucket) getBytesWithTTL(ctx context.Context, rawKey string) ([]byte, time.Duration, error) {
+	var getCmd *redis.StringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := redisstarter.RawRedisClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, rawKey)
+		ttlCmd = pipe.PTTL(ctx, rawKey)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, err
+	}
+	bytes, err := getCmd.Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, 0, ErrCacheMiss
+		}
+		return nil, 0, err
+	}
+	// 未设置过期时间的key返回负值 统一视为不限制
+	ttl := ttlCmd.Val()
+	if ttl < 0 {
+		ttl = 0
+	}
+	return unwrapRedisTTL(bytes), ttl, nil
+}
+
+func (m *redisCacheBucket) PutWithContext(ctx context.Context, key CacheKey, data any, keyAppend ...interface{}) error {
+	return m.PutWithExpire(ctx, key, data, 0, keyAppend...)
+}
+
+func (m *redisCacheBucket) PutWithExpire(ctx context.Context, key CacheKey, data any, expire time.Duration, keyAppend ...interface{}) error {
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpPut, m.stats, rawKey)
+	bytes, err := m.codec.Encode(data)
+	if err == nil {
+		err = m.putBytes(ctx, m.keyPrefix+rawKey, bytes, expire)
+		m.stats.recordTierError(tierRedis, err)
+	}
+	m.stats.recordPut(successCount(err), err, start)
+	endSpan(span, SpanEnd{Err: err})
+	return err
+}
+
+// putBytes 设置原始缓存数据 expire 小于等于零时使用存储桶配置的过期时间
+// 单独指定过期时间时在缓存值前附加该过期时间 滑动过期与 Touch 据此延长
+func (m *redisCacheBucket) putBytes(ctx context.Context, rawKey string, bytes []byte, expire time.Duration) error {
+	if expire <= 0 {
+		expire = m.defaultExpire()
+	} else if expire != m.defaultExpire() && !isNotFoundMarker(bytes) {
+		bytes = wrapRedisTTL(bytes, expire)
+	}
+	return redisstarter.RawRedisClient().Set(ctx, rawKey, bytes, expire).Err()
+}
+
+func (m *redisCacheBucket) putNotFound(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	if m.negativeExpire <= 0 {
+		return nil
+	}
+	return m.putBytes(ctx, m.rawKey(key, keyAppend...), notFoundMarker, m.negativeExpire)
+}
+
+func (m *redisCacheBucket) EvictWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	start := time.Now()
+	rawKey := key.RawKeyString(keyAppend...)
+	ctx, span := startSpan(ctx, TraceOpEvict, m.stats, rawKey)
+	err := m.del(ctx, m.keyPrefix+rawKey)
+	m.stats.recordTierError(tierRedis, err)
+	m.stats.recordEvict(1, err, start)
+	endSpan(span, SpanEnd{Err: err})
+	return err
+}
+
+// del 删除缓存数据 缓存不存在时返回 ErrCacheMiss
+func (m *redisCacheBucket) del(ctx context.Context, rawKey string) error {
+	result, err := redisstarter.RawRedisClient().Del(ctx, rawKey).Result()
+	if err != nil {
+		return err
+	}
 	if result > 0 {
 		return nil
 	}
 	return ErrCacheMiss
 }
+
+// mGetBytes 批量获取原始缓存数据 未命中的key对应nil
+// 集群模式下多个key可能分布在不同的槽，此时使用管道逐个获取
+func mGetBytes(ctx context.Context, rawKeys []string) ([][]byte, error) {
+	result := make([][]byte, len(rawKeys))
+	if len(rawKeys) == 0 {
+		return result, nil
+	}
+	client := redisstarter.RawRedisClient()
+	if _, ok := client.(*redis.ClusterClient); !ok {
+		values, err := client.MGet(ctx, rawKeys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if str, ok := v.(string); ok {
+				result[i] = unwrapRedisTTL([]byte(str))
+			}
+		}
+		return result, nil
+	}
+	cmds := make([]*redis.StringCmd, len(rawKeys))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, rawKey := range rawKeys {
+			cmds[i] = pipe.Get(ctx, rawKey)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if bytes, e := cmd.Bytes(); e == nil {
+			result[i] = unwrapRedisTTL(bytes)
+		}
+	}
+	return result, nil
+}
+
+// mGetBytesWithTTL 批量获取原始缓存数据以及剩余的过期时间 未命中的key对应nil
+func mGetBytesWithTTL(ctx context.Context, rawKeys []string) ([][]byte, []time.Duration, error) {
+	result := make([][]byte, len(rawKeys))
+	ttls := make([]time.Duration, len(rawKeys))
+	if len(rawKeys) == 0 {
+		return result, ttls, nil
+	}
+	getCmds := make([]*redis.StringCmd, len(rawKeys))
+	ttlCmds := make([]*redis.DurationCmd, len(rawKeys))
+	_, err := redisstarter.RawRedisClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, rawKey := range rawKeys {
+			getCmds[i] = pipe.Get(ctx, rawKey)
+			ttlCmds[i] = pipe.PTTL(ctx, rawKey)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+	for i := range rawKeys {
+		if bytes, e := getCmds[i].Bytes(); e == nil {
+			result[i] = unwrapRedisTTL(bytes)
+			if ttl := ttlCmds[i].Val(); ttl > 0 {
+				ttls[i] = ttl
+			}
+		}
+	}
+	return result, ttls, nil
+}
+
+// mPutBytes 批量设置原始缓存数据
+func mPutBytes(ctx context.Context, rawKeys []string, values [][]byte, expire time.Duration) error {
+	if len(rawKeys) == 0 {
+		return nil
+	}
+	_, err := redisstarter.RawRedisClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, rawKey := range rawKeys {
+			pipe.Set(ctx, rawKey, values[i], expire)
+		}
+		return nil
+	})
+	return err
+}
+
+// mDel 批量删除 集群模式下多个key可能分布在不同的槽，使用管道逐个删除
+func mDel(ctx context.Context, rawKeys []string) error {
+	if len(rawKeys) == 0 {
+		return nil
+	}
+	_, err := redisstarter.RawRedisClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, rawKey := range rawKeys {
+			pipe.Del(ctx, rawKey)
+		}
+		return nil
+	})
+	return err
+}
+
+// scanKeys 使用SCAN渐进式遍历匹配的key，避免阻塞redis 集群模式下将并发遍历所有主节点
+func scanKeys(ctx context.Context, match string, count int64, fn func(rawKeys []string) error) error {
+	client := redisstarter.RawRedisClient()
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scanNodeKeys(ctx, node, match, count, fn)
+		})
+	}
+	return scanNodeKeys(ctx, client, match, count, fn)
+}
+
+func scanNodeKeys(ctx context.Context, client redis.Cmdable, match string, count int64, fn func(rawKeys []string) error) error {
+	var cursor uint64
+	for {
+		rawKeys, next, err := client.Scan(ctx, cursor, match, count).Result()
+		if err != nil {
+			return err
+		}
+		if len(rawKeys) > 0 {
+			if err = fn(rawKeys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// mUnlink 批量异步删除 由redis后台线程回收内存
+func mUnlink(ctx context.Context, rawKeys []string) error {
+	if len(rawKeys) == 0 {
+		return nil
+	}
+	_, err := redisstarter.RawRedisClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, rawKey := range rawKeys {
+			pipe.Unlink(ctx, rawKey)
+		}
+		return nil
+	})
+	return err
+}
+
+// escapeKeyPattern 转义key中的glob匹配字符
+func escapeKeyPattern(key string) string {
+	var builder strings.Builder
+	for _, c := range key {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(c)
+	}
+	return builder.String()
+}
+
+// rawKeys 获取批量key在redis中实际存储的key
+func (m *redisCacheBucket) rawKeys(keys []BatchKey) []string {
+	rawKeys := make([]string, len(keys))
+	for i, key := range keys {
+		rawKeys[i] = m.keyPrefix + key.RawKeyString()
+	}
+	return rawKeys
+}
+
+func (m *redisCacheBucket) MGet(ctx context.Context, keys []BatchKey, results []any) ([]bool, error) {
+	if err := checkBatchArgs(ctx, keys, results); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	values, err := mGetBytes(ctx, m.rawKeys(keys))
+	if err != nil {
+		m.stats.recordTierError(tierRedis, err)
+		m.stats.recordMGet(nil, err, start)
+		return nil, err
+	}
+	hits := make([]bool, len(keys))
+	for i, v := range values {
+		hits[i] = v != nil && !isNotFoundMarker(v) && m.codec.Decode(v, results[i]) == nil
+		if hits[i] {
+			m.slide(m.keyPrefix + keys[i].RawKeyString())
+		}
+	}
+	m.stats.recordHits(tierRedis, hits)
+	m.stats.recordMGet(hits, nil, start)
+	return hits, nil
+}
+
+func (m *redisCacheBucket) MPut(ctx context.Context, keys []BatchKey, data []any) error {
+	if err := checkBatchArgs(ctx, keys, data); err != nil {
+		return err
+	}
+	start := time.Now()
+	values := make([][]byte, len(data))
+	for i, v := range data {
+		bytes, err := m.codec.Encode(v)
+		if err != nil {
+			m.stats.recordPut(0, err, start)
+			return err
+		}
+		values[i] = bytes
+	}
+	err := mPutBytes(ctx, m.rawKeys(keys), values, m.defaultExpire())
+	m.stats.recordTierError(tierRedis, err)
+	if err != nil {
+		m.stats.recordPut(0, err, start)
+	} else {
+		m.stats.recordPut(len(keys), nil, start)
+	}
+	return err
+}
+
+func (m *redisCacheBucket) MEvict(ctx context.Context, keys []BatchKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := mDel(ctx, m.rawKeys(keys))
+	m.stats.recordTierError(tierRedis, err)
+	m.stats.recordEvict(len(keys), err, start)
+	return err
+}
+
+// clearPrefix 删除指定前缀下的所有key
+func clearPrefix(ctx context.Context, keyPrefix string) error {
+	return scanKeys(ctx, escapeKeyPattern(keyPrefix)+"*", scanCount, func(rawKeys []string) error {
+		return mUnlink(ctx, rawKeys)
+	})
+}
+
+func (m *redisCacheBucket) Clear(ctx context.Context) error {
+	return clearPrefix(ctx, m.keyPrefix)
+}
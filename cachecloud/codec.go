@@ -0,0 +1,51 @@
+package cachecloud
+
+import (
+	"github.com/acexy/golang-toolkit/util/gob"
+	"github.com/acexy/golang-toolkit/util/json"
+)
+
+// Codec 缓存值编解码器 redis缓存、内存缓存以及同步消息中的数据摘要均使用存储桶配置的编解码器
+type Codec interface {
+	// Encode 将缓存值编码为字节
+	Encode(data any) ([]byte, error)
+	// Decode 将字节解码至值类型指针
+	Decode(bytes []byte, result any) error
+}
+
+var (
+	// GobCodec gob编解码 默认编解码器
+	GobCodec Codec = gobCodec{}
+	// JSONCodec json编解码 便于其它语言的服务读取共享的redis缓存
+	JSONCodec Codec = jsonCodec{}
+)
+
+// decodeValue 解码缓存值 "不存在"标记返回 ErrCachedNotFound
+func decodeValue(codec Codec, bytes []byte, result any) error {
+	if isNotFoundMarker(bytes) {
+		return ErrCachedNotFound
+	}
+	return codec.Decode(bytes, result)
+}
+
+type gobCodec struct {
+}
+
+func (gobCodec) Encode(data any) ([]byte, error) {
+	return gob.Encode(data)
+}
+
+func (gobCodec) Decode(bytes []byte, result any) error {
+	return gob.Decode(bytes, result)
+}
+
+type jsonCodec struct {
+}
+
+func (jsonCodec) Encode(data any) ([]byte, error) {
+	return json.ToBytesError(data)
+}
+
+func (jsonCodec) Decode(bytes []byte, result any) error {
+	return json.ParseBytesError(bytes, result)
+}
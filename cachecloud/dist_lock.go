@@ -0,0 +1,143 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/bsm/redislock"
+	"github.com/golang-acexy/starter-redis/redisstarter"
+)
+
+// 分布式重建锁：多个实例同时未命中同一redis/二级缓存key时，仅获得锁的实例调用supplier，其余实例等待缓存重建完成
+
+const (
+	defaultDistLockTTL          = 5 * time.Second
+	defaultDistLockWaitTimeout  = 3 * time.Second
+	defaultDistLockPollInterval = 50 * time.Millisecond
+)
+
+// ErrRebuildWaitTimeout 等待其它实例重建缓存超时
+var ErrRebuildWaitTimeout = errors.New("wait cache rebuild timeout")
+
+// DistLockFallback 等待分布式锁超时或获取锁失败后的处理方式
+type DistLockFallback int
+
+const (
+	// DistLockFallbackSupplier 由当前实例调用supplier重建缓存
+	DistLockFallbackSupplier DistLockFallback = iota
+	// DistLockFallbackError 返回错误 ErrRebuildWaitTimeout 或获取锁时发生的错误
+	DistLockFallbackError
+)
+
+// DistLockOption 分布式重建锁设置 仅对redis缓存和二级缓存生效
+type DistLockOption struct {
+	// LockTTL 锁自动释放时间 应大于supplier的最长执行时间 零值时默认5秒
+	LockTTL time.Duration
+	// WaitTimeout 未获得锁时等待其它实例重建缓存的最长时间 零值时默认3秒
+	WaitTimeout time.Duration
+	// PollInterval 等待期间检查缓存的间隔 零值时默认50毫秒
+	PollInterval time.Duration
+	// Fallback 等待超时或获取锁失败后的处理方式
+	Fallback DistLockFallback
+}
+
+func (d DistLockOption) lockTTL() time.Duration {
+	if d.LockTTL > 0 {
+		return d.LockTTL
+	}
+	return defaultDistLockTTL
+}
+
+func (d DistLockOption) waitTimeout() time.Duration {
+	if d.WaitTimeout > 0 {
+		return d.WaitTimeout
+	}
+	return defaultDistLockWaitTimeout
+}
+
+func (d DistLockOption) pollInterval() time.Duration {
+	if d.PollInterval > 0 {
+		return d.PollInterval
+	}
+	return defaultDistLockPollInterval
+}
+
+// hasRedisTier 存储桶是否使用redis存储 仅此类存储桶的缓存可被其它实例读取
+func hasRedisTier(bucket CacheBucket) bool {
+	switch bucket.(type) {
+	case *redisCacheBucket, *secondLevelCacheBucket:
+		return true
+	}
+	return false
+}
+
+// distLockKey 分布式重建锁的key
+func distLockKey(bucketName BucketName, rawKey string, ttl time.Duration) redisstarter.RedisKey {
+	prefix := "cache-lock:"
+	if serviceNamePrefix != "" {
+		prefix = serviceNamePrefix + ":" + prefix
+	}
+	return redisstarter.NewRedisKey(prefix+string(bucketName)+":"+rawKey, ttl)
+}
+
+// rebuildWithDistLock 获得分布式锁后执行rebuild，未获得锁时等待其它实例重建的缓存
+func rebuildWithDistLock[T any](ctx context.Context, bucket CacheBucket, bucketName BucketName, cacheKey CacheKey, option DistLockOption,
+	rebuild func() (any, error), keyAppend ...interface{}) (any, error) {
+	rawKey := cacheKey.RawKeyString(keyAppend...)
+	lockKey := distLockKey(bucketName, rawKey, option.lockTTL())
+	deadline := time.Now().Add(option.waitTimeout())
+	fallback := func(err error) (any, error) {
+		if option.Fallback == DistLockFallbackSupplier {
+			return rebuild()
+		}
+		return nil, err
+	}
+	for {
+		locker, err := redisstarter.TryAndGetLockerWithContext(ctx, lockKey, nil)
+		if err == nil {
+			return rebuildLocked[T](ctx, locker, bucket, cacheKey, rebuild, keyAppend...)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, redislock.ErrNotObtained) {
+			logger.Logrus().Warningln("obtain rebuild lock failed", bucketName, rawKey, err)
+			return fallback(err)
+		}
+		// 等待持有锁的实例完成重建
+		value := new(T)
+		if getErr := bucket.GetWithContext(ctx, cacheKey, value, keyAppend...); getErr == nil {
+			return value, nil
+		} else if errors.Is(getErr, ErrCachedNotFound) {
+			return nil, getErr
+		}
+		if time.Now().After(deadline) {
+			logger.Logrus().Traceln("wait cache rebuild timeout", bucketName, rawKey)
+			return fallback(ErrRebuildWaitTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(option.pollInterval()):
+		}
+	}
+}
+
+func rebuildLocked[T any](ctx context.Context, locker *redisstarter.Locker, bucket CacheBucket, cacheKey CacheKey,
+	rebuild func() (any, error), keyAppend ...interface{}) (any, error) {
+	defer func() {
+		if err := locker.ReleaseWithCtx(context.Background()); err != nil && !errors.Is(err, redislock.ErrLockNotHeld) {
+			logger.Logrus().Warningln("release rebuild lock failed", cacheKey.RawKeyString(keyAppend...), err)
+		}
+	}()
+	// 获得锁后再次检查缓存 其它实例可能刚刚完成重建
+	value := new(T)
+	if err := bucket.GetWithContext(ctx, cacheKey, value, keyAppend...); err == nil {
+		return value, nil
+	} else if errors.Is(err, ErrCachedNotFound) {
+		return nil, err
+	}
+	return rebuild()
+}
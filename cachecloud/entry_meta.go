@@ -0,0 +1,205 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-acexy/starter-redis/redisstarter"
+)
+
+// 缓存元信息：查询缓存是否存在、剩余过期时间以及所在的缓存层，不记录统计、不重建二级缓存的内存缓存
+
+// TierMeta 单个缓存层中的缓存信息
+type TierMeta struct {
+	Exists bool
+	TTL    time.Duration // 剩余过期时间 零值表示不限制
+}
+
+// EntryMeta 缓存元信息
+type EntryMeta struct {
+	Tier     string // 应答的缓存层 TraceTierLocal 或 TraceTierRedis
+	Local    TierMeta
+	Redis    TierMeta
+	Size     int    // 存储的数据长度(字节) 仅 GetWithMeta 返回
+	Checksum string // 存储的数据摘要 与同步消息中的数据摘要一致 仅 GetWithMeta 返回
+	NotFound bool   // 缓存的是"不存在"标记
+}
+
+// TTL 应答的缓存层中的剩余过期时间
+func (e EntryMeta) TTL() time.Duration {
+	if e.Tier == TraceTierRedis {
+		return e.Redis.TTL
+	}
+	return e.Local.TTL
+}
+
+// metaCacheBucket 支持查询缓存元信息的存储桶
+type metaCacheBucket interface {
+	// entryMeta 查询缓存元信息 withValue 为true时同时返回原始缓存数据 缓存不存在时返回 ErrCacheMiss
+	entryMeta(ctx context.Context, rawKey string, withValue bool) (EntryMeta, []byte, error)
+}
+
+// getMetaBucket 获取支持查询缓存元信息的存储桶
+func getMetaBucket(bucketName BucketName) (CacheBucket, metaCacheBucket, error) {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return nil, nil, errors.New("bucket not found")
+	}
+	b, ok := bucket.(metaCacheBucket)
+	if !ok {
+		return nil, nil, errors.New("bucket not support entry meta")
+	}
+	return bucket, b, nil
+}
+
+func getEntryMeta(ctx context.Context, bucketName BucketName, cacheKey CacheKey, keyAppend ...interface{}) (EntryMeta, error) {
+	_, b, err := getMetaBucket(bucketName)
+	if err != nil {
+		return EntryMeta{}, err
+	}
+	if err = ctx.Err(); err != nil {
+		return EntryMeta{}, err
+	}
+	meta, _, err := b.entryMeta(ctx, cacheKey.RawKeyString(keyAppend...), false)
+	return meta, err
+}
+
+// Exists 指定的缓存是否存在 包含"不存在"标记
+func Exists(ctx context.Context, bucketName BucketName, cacheKey CacheKey, keyAppend ...interface{}) (bool, error) {
+	_, err := getEntryMeta(ctx, bucketName, cacheKey, keyAppend...)
+	if err != nil {
+		if errors.Is(err, ErrCacheMiss) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// TTL 获取缓存的剩余过期时间 零值表示不限制 缓存不存在时返回 ErrCacheMiss
+// 二级缓存返回redis中的剩余过期时间 内存缓存不会晚于redis缓存过期
+func TTL(ctx context.Context, bucketName BucketName, cacheKey CacheKey, keyAppend ...interface{}) (time.Duration, error) {
+	meta, err := getEntryMeta(ctx, bucketName, cacheKey, keyAppend...)
+	if err != nil {
+		return 0, err
+	}
+	if meta.Redis.Exists {
+		return meta.Redis.TTL, nil
+	}
+	return meta.Local.TTL, nil
+}
+
+// GetWithMeta 获取缓存值以及缓存元信息 result 为空时仅获取元信息
+// 缓存不存在时返回 ErrCacheMiss 缓存的是"不存在"标记时返回元信息以及 ErrCachedNotFound
+func GetWithMeta(ctx context.Context, bucketName BucketName, cacheKey CacheKey, result any, keyAppend ...interface{}) (EntryMeta, error) {
+	bucket, b, err := getMetaBucket(bucketName)
+	if err != nil {
+		return EntryMeta{}, err
+	}
+	if err = ctx.Err(); err != nil {
+		return EntryMeta{}, err
+	}
+	meta, bytes, err := b.entryMeta(ctx, cacheKey.RawKeyString(keyAppend...), true)
+	if err != nil {
+		return meta, err
+	}
+	meta.Size = len(bytes)
+	meta.Checksum = dataSum(bytes)
+	meta.NotFound = isNotFoundMarker(bytes)
+	if meta.NotFound {
+		return meta, ErrCachedNotFound
+	}
+	if result == nil {
+		return meta, nil
+	}
+	return meta, decodeValue(codecOf(bucket), bytes, result)
+}
+
+// codecOf 获取存储桶使用的缓存值编解码器
+func codecOf(bucket CacheBucket) Codec {
+	switch b := bucket.(type) {
+	case *memeCacheBucket:
+		return b.bucket.codec
+	case *distMemeCacheBucket:
+		return b.bucket.codec
+	case *redisCacheBucket:
+		return b.codec
+	case *secondLevelCacheBucket:
+		return b.redisBucket.codec
+	}
+	return GobCodec
+}
+
+// localMeta 查询本地缓存中的缓存信息
+func (l *localCacheBucket) localMeta(rawKey string) (TierMeta, []byte, error) {
+	bytes, expireAt, _, _, err := l.peekEntry(rawKey)
+	if err != nil {
+		return TierMeta{}, nil, err
+	}
+	meta := TierMeta{Exists: true}
+	if expireAt > 0 {
+		meta.TTL = max(time.Until(time.Unix(0, expireAt)), time.Nanosecond)
+	}
+	return meta, bytes, nil
+}
+
+// redisMeta 查询redis中的缓存信息 withValue 为false时仅查询剩余过期时间
+func (m *redisCacheBucket) redisMeta(ctx context.Context, rawKey string, withValue bool) (TierMeta, []byte, error) {
+	rawKey = m.keyPrefix + rawKey
+	if withValue {
+		bytes, ttl, err := m.getBytesWithTTL(ctx, rawKey)
+		if err != nil {
+			return TierMeta{}, nil, err
+		}
+		return TierMeta{Exists: true, TTL: ttl}, bytes, nil
+	}
+	ttl, err := redisstarter.RawRedisClient().PTTL(ctx, rawKey).Result()
+	if err != nil {
+		return TierMeta{}, nil, err
+	}
+	// key不存在时返回-2 未设置过期时间时返回-1
+	if ttl == -2 {
+		return TierMeta{}, nil, ErrCacheMiss
+	}
+	return TierMeta{Exists: true, TTL: max(ttl, 0)}, nil, nil
+}
+
+func (m *memeCacheBucket) entryMeta(_ context.Context, rawKey string, _ bool) (EntryMeta, []byte, error) {
+	local, bytes, err := m.bucket.localMeta(rawKey)
+	return EntryMeta{Tier: TraceTierLocal, Local: local}, bytes, err
+}
+
+func (m *distMemeCacheBucket) entryMeta(_ context.Context, rawKey string, _ bool) (EntryMeta, []byte, error) {
+	local, bytes, err := m.bucket.localMeta(rawKey)
+	return EntryMeta{Tier: TraceTierLocal, Local: local}, bytes, err
+}
+
+func (m *redisCacheBucket) entryMeta(ctx context.Context, rawKey string, withValue bool) (EntryMeta, []byte, error) {
+	redisTier, bytes, err := m.redisMeta(ctx, rawKey, withValue)
+	return EntryMeta{Tier: TraceTierRedis, Redis: redisTier}, bytes, err
+}
+
+// entryMeta 同时查询两层缓存 优先由内存缓存应答 内存缓存未命中时不会通过redis重建
+func (m *secondLevelCacheBucket) entryMeta(ctx context.Context, rawKey string, withValue bool) (EntryMeta, []byte, error) {
+	var meta EntryMeta
+	local, bytes, localErr := m.memBucket.localMeta(rawKey)
+	if localErr != nil && !errors.Is(localErr, ErrCacheMiss) {
+		return meta, nil, localErr
+	}
+	meta.Local = local
+	redisTier, redisBytes, redisErr := m.redisBucket.redisMeta(ctx, rawKey, withValue && !local.Exists)
+	if redisErr != nil && !errors.Is(redisErr, ErrCacheMiss) {
+		return meta, nil, redisErr
+	}
+	meta.Redis = redisTier
+	switch {
+	case local.Exists:
+		meta.Tier = TraceTierLocal
+		return meta, bytes, nil
+	case redisTier.Exists:
+		meta.Tier = TraceTierRedis
+		return meta, redisBytes, nil
+	}
+	return meta, nil, ErrCacheMiss
+}
@@ -0,0 +1,29 @@
+package cachecloud
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalMetaNoSideEffect(t *testing.T) {
+	local, err := newLocalCacheBucket(NewMemCacheConfig("meta", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = local.putBytes("short", []byte("v"), 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, _, err = local.localMeta("short"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("localMeta(short) error = %v, want ErrCacheMiss", err)
+	}
+	if _, err = local.cache.Get("short"); err != nil {
+		t.Errorf("expired entry removed by localMeta: %v", err)
+	}
+	if _, err = local.getBytes("short"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("getBytes(short) error = %v, want ErrCacheMiss", err)
+	}
+	if _, err = local.cache.Get("short"); err == nil {
+		t.Error("expired entry kept after getBytes")
+	}
+}
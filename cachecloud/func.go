@@ -1,6 +1,7 @@
 package cachecloud
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -49,6 +50,11 @@ func NewCacheKey(format string) CacheKey {
 	return CacheKey{KeyFormat: format}
 }
 
+// NewBatchKey 创建一个批量操作的缓存key
+func NewBatchKey(key CacheKey, keyAppend ...interface{}) BatchKey {
+	return BatchKey{Key: key, KeyAppend: keyAppend}
+}
+
 // GetBucket 通过指定的存储桶，获取最佳匹配的存储桶实例
 func GetBucket(bucketName BucketName) CacheBucket {
 	return getBucket(bucketName)
@@ -61,48 +67,228 @@ func GetBucketByType(bucketName BucketName, typ BucketType) CacheBucket {
 
 // GetCacheValue 通过指定的存储桶和缓存key，获取缓存值
 func GetCacheValue(bucketName BucketName, cacheKey CacheKey, result any, keyAppend ...interface{}) error {
+	return GetCacheValueWithContext(context.Background(), bucketName, cacheKey, result, keyAppend...)
+}
+
+// GetCacheValueWithContext 通过指定的存储桶和缓存key，获取缓存值 遵循ctx的超时与取消
+func GetCacheValueWithContext(ctx context.Context, bucketName BucketName, cacheKey CacheKey, result any, keyAppend ...interface{}) error {
 	bucket := getBucket(bucketName)
 	if bucket == nil {
 		return errors.New("bucket not found")
 	}
-	return bucket.Get(cacheKey, result, keyAppend...)
+	return bucket.GetWithContext(ctx, cacheKey, result, keyAppend...)
 }
 
 // PutCacheValue 通过指定的存储桶和缓存key，设置缓存值
 func PutCacheValue(bucketName BucketName, cacheKey CacheKey, data any, keyAppend ...interface{}) error {
+	return PutCacheValueWithContext(context.Background(), bucketName, cacheKey, data, keyAppend...)
+}
+
+// PutCacheValueWithContext 通过指定的存储桶和缓存key，设置缓存值 遵循ctx的超时与取消
+func PutCacheValueWithContext(ctx context.Context, bucketName BucketName, cacheKey CacheKey, data any, keyAppend ...interface{}) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	return bucket.PutWithContext(ctx, cacheKey, data, keyAppend...)
+}
+
+// PutCacheValueWithExpire 通过指定的存储桶和缓存key，设置缓存值并单独指定该条缓存的过期时间
+func PutCacheValueWithExpire(ctx context.Context, bucketName BucketName, cacheKey CacheKey, data any, expire time.Duration, keyAppend ...interface{}) error {
 	bucket := getBucket(bucketName)
 	if bucket == nil {
 		return errors.New("bucket not found")
 	}
-	return bucket.Put(cacheKey, data, keyAppend...)
+	return bucket.PutWithExpire(ctx, cacheKey, data, expire, keyAppend...)
+}
+
+// PutCacheValueWithExpireAt 通过指定的存储桶和缓存key，设置缓存值并指定该条缓存的绝对过期时间
+func PutCacheValueWithExpireAt(ctx context.Context, bucketName BucketName, cacheKey CacheKey, data any, expireAt time.Time, keyAppend ...interface{}) error {
+	expire, err := expireUntil(expireAt)
+	if err != nil {
+		return err
+	}
+	return PutCacheValueWithExpire(ctx, bucketName, cacheKey, data, expire, keyAppend...)
 }
 
 // EvictCache 通过指定的存储桶和缓存key，删除缓存值
 func EvictCache(bucketName BucketName, cacheKey CacheKey, keyAppend ...interface{}) error {
+	return EvictCacheWithContext(context.Background(), bucketName, cacheKey, keyAppend...)
+}
+
+// EvictCacheWithContext 通过指定的存储桶和缓存key，删除缓存值 遵循ctx的超时与取消
+func EvictCacheWithContext(ctx context.Context, bucketName BucketName, cacheKey CacheKey, keyAppend ...interface{}) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	return bucket.EvictWithContext(ctx, cacheKey, keyAppend...)
+}
+
+// MGetCacheValue 通过指定的存储桶批量获取缓存值 results 与 keys 一一对应且为值类型指针，返回每个key是否命中
+func MGetCacheValue(ctx context.Context, bucketName BucketName, keys []BatchKey, results []any) ([]bool, error) {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return nil, errors.New("bucket not found")
+	}
+	return bucket.MGet(ctx, keys, results)
+}
+
+// MPutCacheValue 通过指定的存储桶批量设置缓存值 data 与 keys 一一对应
+func MPutCacheValue(ctx context.Context, bucketName BucketName, keys []BatchKey, data []any) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	return bucket.MPut(ctx, keys, data)
+}
+
+// MEvictCache 通过指定的存储桶批量删除缓存值
+func MEvictCache(ctx context.Context, bucketName BucketName, keys []BatchKey) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	return bucket.MEvict(ctx, keys)
+}
+
+// ClearBucket 清空指定存储桶中所有缓存
+func ClearBucket(ctx context.Context, bucketName BucketName) error {
 	bucket := getBucket(bucketName)
 	if bucket == nil {
 		return errors.New("bucket not found")
 	}
-	return bucket.Evict(cacheKey, keyAppend...)
+	return bucket.Clear(ctx)
 }
 
 // Cacheable 通过指定的存储桶和缓存key，获取缓存值，如果缓存值不存在，则调用supplier获取值，并设置缓存值
 func Cacheable[T any](bucketName BucketName, cacheKey CacheKey, result *T, supplier Supplier[*T], keyAppend ...interface{}) error {
+	return CacheableWithContext[T](context.Background(), bucketName, cacheKey, result, supplier, keyAppend...)
+}
+
+// CacheableWithContext 通过指定的存储桶和缓存key，获取缓存值，如果缓存值不存在，则调用supplier获取值，并设置缓存值 遵循ctx的超时与取消
+func CacheableWithContext[T any](ctx context.Context, bucketName BucketName, cacheKey CacheKey, result *T, supplier Supplier[*T], keyAppend ...interface{}) error {
+	return CacheableWithOption[T](ctx, bucketName, cacheKey, result, supplier, CacheableOption{}, keyAppend...)
+}
+
+// CacheableWithOption 通过指定的存储桶和缓存key，获取缓存值，如果缓存值不存在，则调用supplier获取值，并按照option设置缓存值
+// 同一存储桶同一缓存key并发未命中时仅有一个supplier调用，其余请求等待该调用的结果并各自解码独立的副本
+// supplier未能获取数据时返回 ErrCacheMiss，存储桶启用否定缓存时后续获取将返回 ErrCachedNotFound 且不再调用supplier
+// 存储桶启用过时数据后台刷新时，已过时的缓存数据直接返回并在后台异步调用supplier刷新
+func CacheableWithOption[T any](ctx context.Context, bucketName BucketName, cacheKey CacheKey, result *T, supplier Supplier[*T], option CacheableOption, keyAppend ...interface{}) error {
+	var load func(ctx context.Context) (*T, error)
+	if supplier != nil {
+		load = func(context.Context) (*T, error) {
+			value, flag := supplier()
+			if !flag {
+				return nil, ErrCacheMiss
+			}
+			return value, nil
+		}
+	}
+	return cacheable[T](ctx, bucketName, cacheKey, result, load, option, keyAppend...)
+}
+
+// CacheableWithLoader 通过指定的存储桶和缓存key，获取缓存值，如果缓存值不存在，则调用loader获取值，并设置缓存值
+// loader返回的错误将原样返回，返回 ErrNotFound 时存储桶启用否定缓存则写入"不存在"标记，其它错误不会被缓存
+func CacheableWithLoader[T any](ctx context.Context, bucketName BucketName, cacheKey CacheKey, result *T, loader Loader[T], keyAppend ...interface{}) error {
+	return CacheableWithLoaderOption[T](ctx, bucketName, cacheKey, result, loader, CacheableOption{}, keyAppend...)
+}
+
+// CacheableWithLoaderOption 与 CacheableWithLoader 一致 并按照option设置缓存值
+func CacheableWithLoaderOption[T any](ctx context.Context, bucketName BucketName, cacheKey CacheKey, result *T, loader Loader[T], option CacheableOption, keyAppend ...interface{}) error {
+	var load func(ctx context.Context) (*T, error)
+	if loader != nil {
+		load = func(ctx context.Context) (*T, error) {
+			value, err := loader(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &value, nil
+		}
+	}
+	return cacheable[T](ctx, bucketName, cacheKey, result, load, option, keyAppend...)
+}
+
+// cacheable 获取缓存值 未命中时通过load重建缓存 load 返回 ErrCacheMiss 或 ErrNotFound 表示数据不存在
+func cacheable[T any](ctx context.Context, bucketName BucketName, cacheKey CacheKey, result *T, load func(ctx context.Context) (*T, error), option CacheableOption, keyAppend ...interface{}) error {
 	bucket := getBucket(bucketName)
 	if bucket == nil {
 		return errors.New("bucket not found")
 	}
-	err := bucket.Get(cacheKey, result, keyAppend...)
-	if errors.Is(err, ErrCacheMiss) {
-		if supplier != nil {
-			value, flag := supplier()
-			if flag {
-				*result = *value
-				return bucket.Put(cacheKey, value, keyAppend...)
-			} else {
-				logger.Logrus().Traceln("rebuild cache failed, supplier get nil data")
+	rawKey := cacheKey.RawKeyString(keyAppend...)
+	stats := statsOf(bucket)
+	ctx, span := startSpan(ctx, TraceOpCacheable, stats, rawKey)
+	rebuild := func(ctx context.Context) (any, error) {
+		stats.addSupplierCall()
+		value, loadErr := load(ctx)
+		if loadErr != nil {
+			if !errors.Is(loadErr, ErrCacheMiss) && !errors.Is(loadErr, ErrNotFound) {
+				return nil, loadErr
 			}
+			logger.Logrus().Traceln("rebuild cache failed, supplier get nil data")
+			if putErr := putNotFound(ctx, bucket, cacheKey, keyAppend...); putErr != nil {
+				logger.Logrus().Warningln("put not found marker failed", rawKey, putErr)
+			}
+			return nil, loadErr
+		}
+		expire, expireErr := option.expire()
+		if expireErr != nil {
+			return value, expireErr
+		}
+		if tagErr := addTags(ctx, bucket, bucketName, rawKey, expire, option.Tags); tagErr != nil {
+			return value, tagErr
+		}
+		return value, bucket.PutWithExpire(ctx, cacheKey, value, expire, keyAppend...)
+	}
+	var err error
+	if staleBucket, ok := asStaleCacheBucket(bucket); ok && load != nil {
+		var stale bool
+		stale, err = staleBucket.getStale(ctx, cacheKey, result, keyAppend...)
+		if err == nil && stale {
+			// 返回过时数据 后台刷新不受调用方ctx取消的影响
+			refreshCtx := context.WithoutCancel(ctx)
+			if refreshStale(flightKey(bucketName, rawKey), func() error {
+				_, refreshErr := rebuild(refreshCtx)
+				return refreshErr
+			}) {
+				stats.addStaleRefresh()
+			}
+		}
+	} else {
+		err = bucket.GetWithContext(ctx, cacheKey, result, keyAppend...)
+	}
+	if !errors.Is(err, ErrCacheMiss) || load == nil {
+		endSpan(span, SpanEnd{Hit: err == nil || errors.Is(err, ErrCachedNotFound), Err: err})
+		return err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		endSpan(span, SpanEnd{Err: ctxErr})
+		return ctxErr
+	}
+	// 重建结果由所有合并的请求共享 不受发起重建的调用方ctx取消的影响
+	flightCtx := context.WithoutCancel(ctx)
+	codec := codecOf(bucket)
+	value, err, shared := cacheableFlight.do(ctx, flightKey(bucketName, rawKey), func() (any, error) {
+		var value any
+		var rebuildErr error
+		if option.DistLock != nil && hasRedisTier(bucket) {
+			value, rebuildErr = rebuildWithDistLock[T](flightCtx, bucket, bucketName, cacheKey, *option.DistLock, func() (any, error) {
+				return rebuild(flightCtx)
+			}, keyAppend...)
+		} else {
+			value, rebuildErr = rebuild(flightCtx)
+		}
+		return newFlightResult[T](codec, value), rebuildErr
+	})
+	if shared {
+		stats.addCoalesced()
+	}
+	if r, ok := value.(*flightResult[T]); ok {
+		if loadErr := r.load(codec, result, shared); loadErr != nil && err == nil {
+			err = loadErr
 		}
 	}
+	endSpan(span, SpanEnd{Tier: TraceTierSupplier, Err: err})
 	return err
 }
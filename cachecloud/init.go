@@ -1,9 +1,14 @@
 package cachecloud
 
 import (
+	"context"
 	"errors"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/acexy/golang-toolkit/logger"
 	"github.com/acexy/golang-toolkit/util/coll"
 	"github.com/acexy/golang-toolkit/util/str"
 )
@@ -17,12 +22,33 @@ var useDistMemCache bool
 var useMemCache bool
 var useRedisCache bool
 
+// 自动启用二级缓存的存储桶
+var promotedBuckets []BucketName
+
+// reservedBucketNames 与内部使用的redis key前缀冲突的存储桶名称
+var reservedBucketNames = []BucketName{"l2", "cache-lock", "cache-tag"}
+
 func Init(option Option, cacheConfigs ...CacheConfig) error {
 	if !str.HasText(option.ServiceName) {
 		return errors.New("service name can not be empty")
 	}
+	for _, config := range cacheConfigs {
+		if err := checkBucketName(config); err != nil {
+			return err
+		}
+	}
 	initOnce.Do(func() {
 		serviceNamePrefix = option.ServiceName
+		cacheTracer = option.Tracer
+		traceHashKey = option.TraceHashKey
+		legacySyncMessage = option.LegacySyncMessage
+		syncGapHook = option.OnSyncGap
+		if option.SyncTransport != nil {
+			syncTransport = option.SyncTransport
+		}
+		if option.AutoEnable2LevelCache {
+			cacheConfigs = promoteLevel2Configs(cacheConfigs)
+		}
 		if len(cacheConfigs) > 0 {
 			// 加载分布式内存缓存设置
 			distMemConfigs := coll.SliceFilter(cacheConfigs, func(e CacheConfig) bool {
@@ -60,3 +86,79 @@ func Init(option Option, cacheConfigs ...CacheConfig) error {
 	})
 	return nil
 }
+
+// checkBucketName 使用redis的存储桶名称不能包含":"且不能使用保留名称 避免存储桶的key前缀包含其它存储桶或内部使用的key
+// 否则清空存储桶、按缓存key格式清除时可能误删其它存储桶的缓存
+func checkBucketName(config CacheConfig) error {
+	if config.typ != BucketTypeRedis && config.typ != BucketTypeLevel2 {
+		return nil
+	}
+	if strings.Contains(string(config.bucketName), ":") || slices.Contains(reservedBucketNames, config.bucketName) {
+		return errors.New("invalid bucket name: " + string(config.bucketName))
+	}
+	return nil
+}
+
+// promoteLevel2Configs 将同名的mem(或dist-mem)与redis存储桶合并为二级缓存存储桶，并移除原始的mem存储桶配置
+// 二级缓存的redis层沿用redis存储桶的key前缀，升级前写入的redis数据仍然可用，通过 GetBucketByType 获取的redis存储桶与二级缓存读写相同的数据
+// 但通过redis存储桶写入或清除缓存时不会通知二级缓存的内存层
+func promoteLevel2Configs(cacheConfigs []CacheConfig) []CacheConfig {
+	result := make([]CacheConfig, 0, len(cacheConfigs))
+	for _, config := range cacheConfigs {
+		if config.typ != BucketTypeMem && config.typ != BucketTypeDistMem {
+			result = append(result, config)
+			continue
+		}
+		redisConfig, ok := coll.SliceFilterFirstOne(cacheConfigs, func(item CacheConfig) bool {
+			return item.typ == BucketTypeRedis && item.bucketName == config.bucketName
+		})
+		if !ok {
+			result = append(result, config)
+			continue
+		}
+		if coll.SliceContains(promotedBuckets, config.bucketName) || coll.SliceAnyContains(cacheConfigs, func(item CacheConfig) bool {
+			return item.typ == BucketTypeLevel2 && item.bucketName == config.bucketName
+		}) {
+			logger.Logrus().Warningln("level-2 bucket already defined, skip auto enable", config.bucketName)
+			result = append(result, config)
+			continue
+		}
+		level2Config := config
+		level2Config.typ = BucketTypeLevel2
+		level2Config.redisExpire = redisConfig.redisExpire
+		level2Config.redisKeyPrefix = redisBucketKeyPrefix(config.bucketName)
+		// redis存储桶的设置决定了已有数据的格式与过期方式 冲突时以redis存储桶为准
+		if redisConfig.codec != nil {
+			level2Config.codec = redisConfig.codec
+		}
+		level2Config.negativeExpire = mergePromotedExpire(config.bucketName, "negative expire", config.negativeExpire, redisConfig.negativeExpire)
+		level2Config.softExpire = mergePromotedExpire(config.bucketName, "soft expire", config.softExpire, redisConfig.softExpire)
+		level2Config.hardExpire = mergePromotedExpire(config.bucketName, "hard expire", config.hardExpire, redisConfig.hardExpire)
+		level2Config.slideInterval = mergePromotedExpire(config.bucketName, "sliding interval", config.slideInterval, redisConfig.slideInterval)
+		result = append(result, level2Config)
+		promotedBuckets = append(promotedBuckets, config.bucketName)
+		logger.Logrus().Infoln("auto enable level-2 cache", config.bucketName, config.typ, "+", BucketTypeRedis)
+	}
+	return result
+}
+
+// mergePromotedExpire 合并mem与redis存储桶的同一项设置 均已设置且不一致时使用redis存储桶的设置
+func mergePromotedExpire(bucketName BucketName, name string, memValue, redisValue time.Duration) time.Duration {
+	if redisValue <= 0 {
+		return memValue
+	}
+	if memValue > 0 && memValue != redisValue {
+		logger.Logrus().Warningln("level-2 bucket config conflict, use redis", bucketName, name, memValue, redisValue)
+	}
+	return redisValue
+}
+
+// PromotedLevel2Buckets 获取通过 Option.AutoEnable2LevelCache 自动启用二级缓存的存储桶 返回副本
+func PromotedLevel2Buckets() []BucketName {
+	return slices.Clone(promotedBuckets)
+}
+
+// Shutdown 应用退出前调用 立即发布所有存储桶暂存的缓存变化事件以及滑动过期暂存的过期时间延长
+func Shutdown(ctx context.Context) error {
+	return errors.Join(flushSyncBatchers(ctx), flushRedisTouchers(ctx))
+}
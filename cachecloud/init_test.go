@@ -0,0 +1,25 @@
+package cachecloud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckBucketName(t *testing.T) {
+	tests := []struct {
+		config  CacheConfig
+		wantErr bool
+	}{
+		{NewRedisCacheConfig("user", time.Hour), false},
+		{NewRedisCacheConfig("user:profile", time.Hour), true},
+		{NewRedisCacheConfig("l2", time.Hour), true},
+		{NewRedisCacheConfig("cache-tag", time.Hour), true},
+		{NewLevel2CacheConfig("a:b", time.Minute, time.Hour), true},
+		{NewMemCacheConfig("a:b", time.Hour), false},
+	}
+	for _, tt := range tests {
+		if err := checkBucketName(tt.config); (err != nil) != tt.wantErr {
+			t.Errorf("checkBucketName(%s %s) error = %v, wantErr %v", tt.config.typ, tt.config.bucketName, err, tt.wantErr)
+		}
+	}
+}
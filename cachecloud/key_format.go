@@ -0,0 +1,149 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 按缓存key格式清除：将 CacheKey.KeyFormat 中的格式化动词视为通配，清除由该格式生成的所有缓存
+// redis中通过SCAN渐进式遍历匹配的key，本地缓存遍历所有缓存key，分布式内存缓存和二级缓存通过同步消息清除所有实例的本地缓存
+// %d 仅匹配整数，其余动词匹配任意字符，因此 "user:%s" 也会匹配 "user:1:profile"
+
+// formatCacheBucket 支持按缓存key格式清除的存储桶
+type formatCacheBucket interface {
+	// evictFormat 清除所有匹配缓存key格式的缓存
+	evictFormat(ctx context.Context, format *keyFormat) error
+}
+
+// keyFormat 编译后的缓存key格式
+type keyFormat struct {
+	format string
+	glob   string         // redis匹配模式 不包含存储桶前缀
+	regexp *regexp.Regexp // 完整匹配原始缓存key
+}
+
+// compileKeyFormat 编译缓存key格式
+func compileKeyFormat(format string) (*keyFormat, error) {
+	var glob, pattern, literal strings.Builder
+	flushLiteral := func() {
+		glob.WriteString(escapeKeyPattern(literal.String()))
+		pattern.WriteString(regexp.QuoteMeta(literal.String()))
+		literal.Reset()
+	}
+	pattern.WriteByte('^')
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			literal.WriteByte(format[i])
+			continue
+		}
+		if format[i+1] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+		// 跳过标记、宽度与精度 直至格式化动词
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[j]) >= 0 {
+			j++
+		}
+		if j == len(format) {
+			return nil, errors.New("bad key format: " + format)
+		}
+		flushLiteral()
+		glob.WriteByte('*')
+		if format[j] == 'd' {
+			pattern.WriteString(`[ +-]*[0-9]+`)
+		} else {
+			pattern.WriteString(`.*`)
+		}
+		i = j
+	}
+	flushLiteral()
+	pattern.WriteByte('$')
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, err
+	}
+	return &keyFormat{format: format, glob: glob.String(), regexp: re}, nil
+}
+
+// match 原始缓存key是否由该格式生成
+func (k *keyFormat) match(rawKey string) bool {
+	return k.regexp.MatchString(rawKey)
+}
+
+// evictRedisFormat 清除redis中匹配缓存key格式的缓存 返回清除的数量
+func evictRedisFormat(ctx context.Context, keyPrefix string, format *keyFormat) (int, error) {
+	// 集群模式下并发遍历各主节点
+	var count atomic.Int64
+	err := scanKeys(ctx, escapeKeyPattern(keyPrefix)+format.glob, scanCount, func(rawKeys []string) error {
+		matched := make([]string, 0, len(rawKeys))
+		for _, rawKey := range rawKeys {
+			if format.match(strings.TrimPrefix(rawKey, keyPrefix)) {
+				matched = append(matched, rawKey)
+			}
+		}
+		count.Add(int64(len(matched)))
+		return mUnlink(ctx, matched)
+	})
+	return int(count.Load()), err
+}
+
+// EvictFormat 清除指定存储桶中所有由cacheKey格式生成的缓存
+func EvictFormat(ctx context.Context, bucketName BucketName, cacheKey CacheKey) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	b, ok := bucket.(formatCacheBucket)
+	if !ok {
+		return errors.New("bucket not support evict format")
+	}
+	format, err := compileKeyFormat(cacheKey.KeyFormat)
+	if err != nil {
+		return err
+	}
+	return b.evictFormat(ctx, format)
+}
+
+func (m *memeCacheBucket) evictFormat(ctx context.Context, format *keyFormat) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	m.bucket.stats.recordEvict(m.bucket.evictMatch(format), nil, start)
+	return nil
+}
+
+func (m *distMemeCacheBucket) evictFormat(ctx context.Context, format *keyFormat) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	m.bucket.stats.recordEvict(m.bucket.evictMatch(format), nil, start)
+	m.publicEvent(ctx, newFormatSyncEntry(format.format))
+	return nil
+}
+
+func (m *redisCacheBucket) evictFormat(ctx context.Context, format *keyFormat) error {
+	start := time.Now()
+	count, err := evictRedisFormat(ctx, m.keyPrefix, format)
+	m.stats.recordTierError(tierRedis, err)
+	m.stats.recordEvict(count, err, start)
+	return err
+}
+
+func (m *secondLevelCacheBucket) evictFormat(ctx context.Context, format *keyFormat) error {
+	start := time.Now()
+	count, redisErr := evictRedisFormat(ctx, m.redisBucket.keyPrefix, format)
+	m.memBucket.stats.recordTierError(tierRedis, redisErr)
+	// 同一缓存通常同时存在于两层 按较多的一层计数
+	count = max(count, m.memBucket.evictMatch(format))
+	m.memBucket.stats.recordEvict(count, redisErr, start)
+	m.publicEvent(ctx, newFormatSyncEntry(format.format))
+	return redisErr
+}
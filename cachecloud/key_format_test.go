@@ -0,0 +1,60 @@
+package cachecloud
+
+import "testing"
+
+func TestCompileKeyFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		glob     string
+		match    []string
+		notMatch []string
+	}{
+		{"user:%d", "user:*", []string{"user:1", "user:-12", "user:007"}, []string{"user:", "user:a", "user:1:profile", "order:1"}},
+		{"user:%s", "user:*", []string{"user:", "user:tom", "user:1:profile"}, []string{"user", "order:1"}},
+		{"user:%d:%s", "user:*:*", []string{"user:1:name", "user:1:"}, []string{"user:a:name", "user:1"}},
+		{"user:%05d", "user:*", []string{"user:00012"}, []string{"user:1a"}},
+		{"user:%[1]d", "user:*", []string{"user:3"}, []string{"user:x"}},
+		{"rate:%d%%", "rate:*%", []string{"rate:5%"}, []string{"rate:5", "rate:5%%"}},
+		{"user:%", "user:%", []string{"user:%"}, []string{"user:1"}},
+		{"a*b?[c]:%v", `a\*b\?\[c\]:*`, []string{"a*b?[c]:1"}, []string{"axb?[c]:1", "a*bx[c]:1", "a*b?c:1"}},
+		{"user.%s", "user.*", []string{"user.1"}, []string{"userx1"}},
+		{"plain", "plain", []string{"plain"}, []string{"plain:1", "plai"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			format, err := compileKeyFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.glob != tt.glob {
+				t.Errorf("glob = %q, want %q", format.glob, tt.glob)
+			}
+			glob, err := globRegexp(format.glob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rawKey := range tt.match {
+				if !format.match(rawKey) {
+					t.Errorf("match(%q) = false", rawKey)
+				}
+				// redis按glob遍历 由格式生成的缓存key都需被glob匹配
+				if !glob.MatchString(rawKey) {
+					t.Errorf("glob %q does not match %q", format.glob, rawKey)
+				}
+			}
+			for _, rawKey := range tt.notMatch {
+				if format.match(rawKey) {
+					t.Errorf("match(%q) = true", rawKey)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileKeyFormatBad(t *testing.T) {
+	for _, format := range []string{"user:%5", "user:%-.2", "%[1]"} {
+		if _, err := compileKeyFormat(format); err == nil {
+			t.Errorf("compileKeyFormat(%q) err = nil", format)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package cachecloud
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"iter"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-acexy/starter-redis/redisstarter"
+	"github.com/redis/go-redis/v9"
+)
+
+// 缓存key遍历：用于调试与数据迁移，遍历存储桶中实际存在的缓存key
+// 内存缓存与分布式内存缓存遍历本实例的本地缓存，redis缓存与二级缓存通过SCAN渐进式遍历redis中的缓存
+// SCAN 遍历期间新增或删除的缓存可能不会返回，同一缓存key也可能返回多次
+
+// KeysOption 缓存key遍历设置
+type KeysOption struct {
+	// Pattern 仅返回匹配的缓存key 语法与redis的glob匹配一致 为空时返回所有缓存key
+	Pattern string
+	// PageSize 每次遍历的数量提示 零值时默认500
+	PageSize int64
+}
+
+func (k KeysOption) pageSize() int64 {
+	if k.PageSize > 0 {
+		return k.PageSize
+	}
+	return scanCount
+}
+
+func (k KeysOption) pattern() string {
+	if k.Pattern != "" {
+		return k.Pattern
+	}
+	return "*"
+}
+
+// keysCacheBucket 支持遍历缓存key的存储桶
+type keysCacheBucket interface {
+	keys(ctx context.Context, option KeysOption) iter.Seq2[string, error]
+}
+
+// Keys 遍历指定存储桶中的原始缓存key 遍历失败时返回错误并结束遍历
+func Keys(ctx context.Context, bucketName BucketName, option ...KeysOption) iter.Seq2[string, error] {
+	var keysOption KeysOption
+	if len(option) > 0 {
+		keysOption = option[0]
+	}
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errorSeq(errors.New("bucket not found"))
+	}
+	b, ok := bucket.(keysCacheBucket)
+	if !ok {
+		return errorSeq(errors.New("bucket not support keys"))
+	}
+	return b.keys(ctx, keysOption)
+}
+
+func errorSeq(err error) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		yield("", err)
+	}
+}
+
+// globRegexp 将redis的glob匹配模式转换为正则表达式
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteByte('^')
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			builder.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			builder.WriteByte(c)
+		case c == '*':
+			builder.WriteString(".*")
+		case c == '?':
+			builder.WriteByte('.')
+		case c == '[':
+			inClass = true
+			builder.WriteByte(c)
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+				builder.WriteByte('^')
+			}
+		default:
+			builder.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	builder.WriteByte('$')
+	return regexp.Compile(builder.String())
+}
+
+// localKeys 遍历本地缓存中未过期的缓存key
+func (l *localCacheBucket) localKeys(ctx context.Context, option KeysOption) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		var re *regexp.Regexp
+		if option.Pattern != "" {
+			var err error
+			if re, err = globRegexp(option.Pattern); err != nil {
+				yield("", err)
+				return
+			}
+		}
+		iterator := l.cache.Iterator()
+		for iterator.SetNext() {
+			if err := ctx.Err(); err != nil {
+				yield("", err)
+				return
+			}
+			info, err := iterator.Value()
+			if err != nil || len(info.Value()) < localHeaderSize {
+				continue
+			}
+			expireAt := int64(binary.BigEndian.Uint64(info.Value()[:localStaleAtOffset]))
+			if expireAt > 0 && time.Now().UnixNano() >= expireAt {
+				continue
+			}
+			if re != nil && !re.MatchString(info.Key()) {
+				continue
+			}
+			if !yield(info.Key(), nil) {
+				return
+			}
+		}
+	}
+}
+
+// scanNodes 获取需要遍历的redis节点 集群模式下为所有主节点
+func scanNodes(ctx context.Context) ([]redis.Cmdable, error) {
+	client := redisstarter.RawRedisClient()
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{client}, nil
+	}
+	var mutex sync.Mutex
+	var nodes []redis.Cmdable
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		nodes = append(nodes, node)
+		return nil
+	})
+	return nodes, err
+}
+
+// redisKeys 逐个节点顺序遍历redis中指定前缀下的缓存key 返回去除前缀后的原始缓存key
+func redisKeys(ctx context.Context, keyPrefix string, option KeysOption) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		nodes, err := scanNodes(ctx)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		match := escapeKeyPattern(keyPrefix) + option.pattern()
+		for _, node := range nodes {
+			var cursor uint64
+			for {
+				rawKeys, next, err := node.Scan(ctx, cursor, match, option.pageSize()).Result()
+				if err != nil {
+					yield("", err)
+					return
+				}
+				for _, rawKey := range rawKeys {
+					if !yield(strings.TrimPrefix(rawKey, keyPrefix), nil) {
+						return
+					}
+				}
+				if next == 0 {
+					break
+				}
+				cursor = next
+			}
+		}
+	}
+}
+
+func (m *memeCacheBucket) keys(ctx context.Context, option KeysOption) iter.Seq2[string, error] {
+	return m.bucket.localKeys(ctx, option)
+}
+
+func (m *distMemeCacheBucket) keys(ctx context.Context, option KeysOption) iter.Seq2[string, error] {
+	return m.bucket.localKeys(ctx, option)
+}
+
+func (m *redisCacheBucket) keys(ctx context.Context, option KeysOption) iter.Seq2[string, error] {
+	return redisKeys(ctx, m.keyPrefix, option)
+}
+
+func (m *secondLevelCacheBucket) keys(ctx context.Context, option KeysOption) iter.Seq2[string, error] {
+	return redisKeys(ctx, m.redisBucket.keyPrefix, option)
+}
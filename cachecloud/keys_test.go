@@ -0,0 +1,41 @@
+package cachecloud
+
+import "testing"
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		match    []string
+		notMatch []string
+	}{
+		{"*", []string{"", "user:1"}, nil},
+		{"user:*", []string{"user:", "user:1:profile"}, []string{"user", "order:1"}},
+		{"user:?", []string{"user:1", "user:a"}, []string{"user:", "user:12"}},
+		{"user:[abc]", []string{"user:a", "user:c"}, []string{"user:d", "user:ab"}},
+		{"user:[a-c]x", []string{"user:bx"}, []string{"user:dx"}},
+		{"user:[^a]", []string{"user:b"}, []string{"user:a", "user:"}},
+		{"user:[*]", []string{"user:*"}, []string{"user:1"}},
+		{`user:\*`, []string{"user:*"}, []string{"user:1"}},
+		{`user:\?\[1\]`, []string{"user:?[1]"}, []string{"user:x1"}},
+		{"user.(1)+", []string{"user.(1)+"}, []string{"userx(1)+", "user.11"}},
+		{"a*b*c", []string{"abc", "a1b2c"}, []string{"ab", "a1b2c3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := globRegexp(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.match {
+				if !re.MatchString(key) {
+					t.Errorf("%q does not match %q", tt.pattern, key)
+				}
+			}
+			for _, key := range tt.notMatch {
+				if re.MatchString(key) {
+					t.Errorf("%q matches %q", tt.pattern, key)
+				}
+			}
+		})
+	}
+}
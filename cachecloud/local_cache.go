@@ -0,0 +1,260 @@
+package cachecloud
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/allegro/bigcache/v3"
+)
+
+// 本地内存缓存：基于bigcache实现，bigcache仅支持存储桶级别的统一过期时间
+// 为支持单条缓存独立的过期时间，每条缓存数据前附加固定长度的头信息，读取时校验头信息中的过期时间
+
+const (
+	// localHeaderSize 头信息长度: 8字节过期时间戳 + 8字节数据过时时间戳 + 8字节写入时的过期时间(纳秒 零值表示不限制)
+	localHeaderSize    = 24
+	localStaleAtOffset = 8
+	localTTLOffset     = 16
+)
+
+type bigCacheLogger struct {
+}
+
+func (l bigCacheLogger) Printf(format string, v ...interface{}) {
+	logger.Logrus().Debugf(format, v...)
+}
+
+// localCacheBucket 本地内存缓存桶
+type localCacheBucket struct {
+	cache  *bigcache.BigCache
+	expire time.Duration
+	codec  Codec
+
+	negativeExpire time.Duration
+	softExpire     time.Duration
+	hardExpire     time.Duration
+
+	gapAction SyncGapAction
+	gapTTL    time.Duration
+
+	batchWindow time.Duration
+	batchSize   int
+
+	slideInterval time.Duration
+
+	stats *bucketStats
+}
+
+func newLocalCacheBucket(config CacheConfig) (*localCacheBucket, error) {
+	expire := config.memExpire
+	bigCacheConfig := bigcache.DefaultConfig(expire)
+	bigCacheConfig.CleanWindow = 5 * time.Second
+	bigCacheConfig.StatsEnabled = false
+	bigCacheConfig.Logger = bigCacheLogger{}
+	cache, err := bigcache.New(context.Background(), bigCacheConfig)
+	if err != nil {
+		return nil, err
+	}
+	softExpire, hardExpire := config.staleExpire()
+	bucket := &localCacheBucket{
+		cache:          cache,
+		expire:         expire,
+		codec:          config.getCodec(),
+		negativeExpire: config.negativeExpire,
+		softExpire:     softExpire,
+		hardExpire:     hardExpire,
+		gapAction:      config.gapAction,
+		gapTTL:         config.gapTTL,
+		batchWindow:    config.batchWindow,
+		batchSize:      config.batchSize,
+		slideInterval:  config.slidingInterval(),
+		stats:          bucketStatsOf(config.bucketName, config.typ),
+	}
+	if bucket.batchSize <= 0 {
+		bucket.batchSize = defaultSyncBatchSize
+	}
+	bucket.stats.local = bucket
+	return bucket, nil
+}
+
+// newLocalCacheBuckets 通过缓存配置创建本地缓存桶 创建失败的存储桶将被忽略
+func newLocalCacheBuckets(configs ...CacheConfig) map[string]*localCacheBucket {
+	buckets := make(map[string]*localCacheBucket, len(configs))
+	for _, v := range configs {
+		if _, ok := buckets[string(v.bucketName)]; ok {
+			logger.Logrus().Warnln("duplicate bucketName", v.bucketName)
+			continue
+		}
+		bucket, err := newLocalCacheBucket(v)
+		if err != nil {
+			logger.Logrus().Errorln("create local cache bucket failed", v.bucketName, err)
+			continue
+		}
+		buckets[string(v.bucketName)] = bucket
+	}
+	return buckets
+}
+
+// localExpire 计算本地缓存实际生效的过期时间 本地缓存的过期时间不会超过存储桶配置的过期时间
+func (l *localCacheBucket) localExpire(expire time.Duration) time.Duration {
+	if expire <= 0 || expire >= l.expire {
+		return 0
+	}
+	return expire
+}
+
+// defaultExpire 未指定过期时间时写入缓存使用的过期时间
+func (l *localCacheBucket) defaultExpire() time.Duration {
+	if l.hardExpire > 0 {
+		return l.hardExpire
+	}
+	return l.expire
+}
+
+// readEntry 获取原始的缓存数据以及过期时间戳、数据过时时间戳 同时清除已过期的数据
+func (l *localCacheBucket) readEntry(rawKey string) ([]byte, int64, int64, error) {
+	bytes, expireAt, staleAt, expired, err := l.peekEntry(rawKey)
+	if expired {
+		_ = l.cache.Delete(rawKey)
+	}
+	return bytes, expireAt, staleAt, err
+}
+
+// peekEntry 获取原始的缓存数据以及过期时间戳、数据过时时间戳 不清除已过期的数据 expired 表示数据已过期或头信息无效
+func (l *localCacheBucket) peekEntry(rawKey string) (bytes []byte, expireAt, staleAt int64, expired bool, err error) {
+	entry, err := l.cache.Get(rawKey)
+	if err != nil {
+		if errors.Is(err, bigcache.ErrEntryNotFound) {
+			return nil, 0, 0, false, ErrCacheMiss
+		}
+		return nil, 0, 0, false, err
+	}
+	if len(entry) < localHeaderSize {
+		return nil, 0, 0, true, ErrCacheMiss
+	}
+	expireAt = int64(binary.BigEndian.Uint64(entry[:localStaleAtOffset]))
+	if expireAt > 0 && time.Now().UnixNano() >= expireAt {
+		return nil, 0, 0, true, ErrCacheMiss
+	}
+	staleAt = int64(binary.BigEndian.Uint64(entry[localStaleAtOffset:localTTLOffset]))
+	return entry[localHeaderSize:], expireAt, staleAt, false, nil
+}
+
+// getEntry 获取原始的缓存数据以及数据过时时间戳
+func (l *localCacheBucket) getEntry(rawKey string) ([]byte, int64, error) {
+	bytes, _, staleAt, err := l.readEntry(rawKey)
+	return bytes, staleAt, err
+}
+
+// getBytes 获取原始的缓存数据
+func (l *localCacheBucket) getBytes(rawKey string) ([]byte, error) {
+	bytes, _, err := l.getEntry(rawKey)
+	return bytes, err
+}
+
+// get 获取缓存数据并反序列化
+func (l *localCacheBucket) get(rawKey string, result any) error {
+	bytes, err := l.getBytes(rawKey)
+	if err != nil {
+		return err
+	}
+	if err = decodeValue(l.codec, bytes, result); err == nil {
+		l.slide(rawKey)
+	}
+	return err
+}
+
+// getStale 获取缓存数据并反序列化 同时返回数据是否已过时
+func (l *localCacheBucket) getStale(rawKey string, result any) (bool, error) {
+	bytes, staleAt, err := l.getEntry(rawKey)
+	if err != nil {
+		return false, err
+	}
+	if err = decodeValue(l.codec, bytes, result); err != nil {
+		return false, err
+	}
+	l.slide(rawKey)
+	return staleAt > 0 && time.Now().UnixNano() >= staleAt, nil
+}
+
+// putEntry 设置原始缓存数据以及数据过时时间戳
+func (l *localCacheBucket) putEntry(rawKey string, bytes []byte, expire time.Duration, staleAt int64) error {
+	entry := make([]byte, localHeaderSize+len(bytes))
+	// 未单独指定过期时间时同样记录存储桶的过期时间 以便获取剩余过期时间
+	if expire = l.localExpire(expire); expire <= 0 {
+		expire = l.expire
+	}
+	if expire > 0 {
+		binary.BigEndian.PutUint64(entry[:localStaleAtOffset], uint64(time.Now().Add(expire).UnixNano()))
+		binary.BigEndian.PutUint64(entry[localTTLOffset:localHeaderSize], uint64(expire))
+	}
+	binary.BigEndian.PutUint64(entry[localStaleAtOffset:localTTLOffset], uint64(staleAt))
+	copy(entry[localHeaderSize:], bytes)
+	return l.cache.Set(rawKey, entry)
+}
+
+// putBytes 设置原始缓存数据 expire 小于等于零时使用存储桶配置的过期时间
+func (l *localCacheBucket) putBytes(rawKey string, bytes []byte, expire time.Duration) error {
+	if expire <= 0 {
+		expire = l.hardExpire
+	}
+	var staleAt int64
+	if l.softExpire > 0 {
+		staleAt = time.Now().Add(l.softExpire).UnixNano()
+	}
+	return l.putEntry(rawKey, bytes, expire, staleAt)
+}
+
+// put 序列化并设置缓存数据
+func (l *localCacheBucket) put(rawKey string, data any, expire time.Duration) error {
+	bytes, err := l.codec.Encode(data)
+	if err != nil {
+		return err
+	}
+	return l.putBytes(rawKey, bytes, expire)
+}
+
+// evict 清除缓存数据
+func (l *localCacheBucket) evict(rawKey string) error {
+	err := l.cache.Delete(rawKey)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return ErrCacheMiss
+	}
+	return err
+}
+
+// mGet 批量获取缓存数据 返回每个key是否命中
+func (l *localCacheBucket) mGet(keys []BatchKey, results []any) []bool {
+	hits := make([]bool, len(keys))
+	for i, key := range keys {
+		hits[i] = l.get(key.RawKeyString(), results[i]) == nil
+	}
+	return hits
+}
+
+// evictMatch 清除所有匹配缓存key格式的缓存数据 返回清除的数量
+func (l *localCacheBucket) evictMatch(format *keyFormat) int {
+	var rawKeys []string
+	iterator := l.cache.Iterator()
+	for iterator.SetNext() {
+		info, err := iterator.Value()
+		if err == nil && format.match(info.Key()) {
+			rawKeys = append(rawKeys, info.Key())
+		}
+	}
+	count := 0
+	for _, rawKey := range rawKeys {
+		if l.cache.Delete(rawKey) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// clear 清空存储桶中所有缓存数据
+func (l *localCacheBucket) clear() error {
+	return l.cache.Reset()
+}
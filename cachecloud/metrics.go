@@ -0,0 +1,182 @@
+package cachecloud
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 监控指标：将所有已初始化存储桶的统计数据按Prometheus文本格式输出，不依赖Prometheus客户端库
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsTiers 各类型存储桶包含的缓存层
+var metricsTiers = map[BucketType][]string{
+	BucketTypeMem:     {"local"},
+	BucketTypeDistMem: {"local", "redis"},
+	BucketTypeRedis:   {"redis"},
+	BucketTypeLevel2:  {"local", "redis"},
+}
+
+// counterMetric 存储桶级别的计数指标
+type counterMetric struct {
+	name  string
+	help  string
+	value func(stats BucketStats) int64
+}
+
+var counterMetrics = []counterMetric{
+	{"cachecloud_misses_total", "Number of gets that missed all cache tiers.", func(s BucketStats) int64 { return s.Misses }},
+	{"cachecloud_negative_hits_total", "Number of gets that hit a cached not-found marker.", func(s BucketStats) int64 { return s.NegativeHits }},
+	{"cachecloud_puts_total", "Number of values written.", func(s BucketStats) int64 { return s.Puts }},
+	{"cachecloud_evictions_total", "Number of values evicted.", func(s BucketStats) int64 { return s.Evictions }},
+	{"cachecloud_errors_total", "Number of cache operations that returned an error.", func(s BucketStats) int64 { return s.Errors }},
+	{"cachecloud_supplier_calls_total", "Number of Cacheable supplier calls.", func(s BucketStats) int64 { return s.SupplierCalls }},
+	{"cachecloud_coalesced_calls_total", "Number of Cacheable rebuilds coalesced into another call.", func(s BucketStats) int64 { return s.CoalescedCalls }},
+	{"cachecloud_stale_refreshes_total", "Number of background refreshes triggered by stale values.", func(s BucketStats) int64 { return s.StaleRefreshes }},
+}
+
+// metricsWriter 按Prometheus文本格式写入指标
+type metricsWriter struct {
+	builder strings.Builder
+}
+
+func (w *metricsWriter) header(name, help, typ string) {
+	w.builder.WriteString("# HELP " + name + " " + help + "\n")
+	w.builder.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sample 写入一条指标 labels 为依次排列的标签名与标签值
+func (w *metricsWriter) sample(name string, value string, labels ...string) {
+	w.builder.WriteString(name)
+	if len(labels) > 0 {
+		w.builder.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.builder.WriteByte(',')
+			}
+			w.builder.WriteString(labels[i])
+			w.builder.WriteString(`="`)
+			w.builder.WriteString(escapeLabelValue(labels[i+1]))
+			w.builder.WriteByte('"')
+		}
+		w.builder.WriteByte('}')
+	}
+	w.builder.WriteByte(' ')
+	w.builder.WriteString(value)
+	w.builder.WriteByte('\n')
+}
+
+// escapeLabelValue 转义标签值中的反斜杠、双引号与换行
+func escapeLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	var builder strings.Builder
+	for _, c := range value {
+		switch c {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '"':
+			builder.WriteString(`\"`)
+		case '\n':
+			builder.WriteString(`\n`)
+		default:
+			builder.WriteRune(c)
+		}
+	}
+	return builder.String()
+}
+
+func formatInt(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+func bucketLabels(stats BucketStats, labels ...string) []string {
+	return append([]string{"bucket", string(stats.BucketName), "type", string(stats.BucketType)}, labels...)
+}
+
+func tierStats(stats BucketStats, tier string) TierStats {
+	if tier == "redis" {
+		return stats.Redis
+	}
+	return stats.Local
+}
+
+func (w *metricsWriter) writeTier(all []BucketStats, name, help string, value func(t TierStats) int64) {
+	w.header(name, help, "counter")
+	for _, stats := range all {
+		for _, tier := range metricsTiers[stats.BucketType] {
+			w.sample(name, formatInt(value(tierStats(stats, tier))), bucketLabels(stats, "tier", tier)...)
+		}
+	}
+}
+
+func (w *metricsWriter) writeLatency(name string, stats BucketStats, op string, latency LatencyStats) {
+	var cumulative int64
+	for i, bound := range latency.Bounds {
+		cumulative += latency.Counts[i]
+		w.sample(name+"_bucket", formatInt(cumulative), bucketLabels(stats, "op", op, "le", formatSeconds(bound))...)
+	}
+	w.sample(name+"_bucket", formatInt(latency.Count), bucketLabels(stats, "op", op, "le", "+Inf")...)
+	w.sample(name+"_sum", formatSeconds(latency.Sum), bucketLabels(stats, "op", op)...)
+	w.sample(name+"_count", formatInt(latency.Count), bucketLabels(stats, "op", op)...)
+}
+
+// writeMetrics 写入所有存储桶的指标
+func writeMetrics(all []BucketStats) string {
+	w := &metricsWriter{}
+	w.writeTier(all, "cachecloud_tier_hits_total", "Number of gets served by the cache tier.", func(t TierStats) int64 { return t.Hits })
+	w.writeTier(all, "cachecloud_tier_misses_total", "Number of gets that missed the cache tier.", func(t TierStats) int64 { return t.Misses })
+	w.writeTier(all, "cachecloud_tier_errors_total", "Number of errors returned by the cache tier.", func(t TierStats) int64 { return t.Errors })
+	for _, metric := range counterMetrics {
+		w.header(metric.name, metric.help, "counter")
+		for _, stats := range all {
+			w.sample(metric.name, formatInt(metric.value(stats)), bucketLabels(stats)...)
+		}
+	}
+
+	w.header("cachecloud_sync_invalidations_total", "Number of cache changes exchanged with other instances.", "counter")
+	for _, stats := range all {
+		if stats.BucketType != BucketTypeDistMem && stats.BucketType != BucketTypeLevel2 {
+			continue
+		}
+		w.sample("cachecloud_sync_invalidations_total", formatInt(stats.InvalidationsSent), bucketLabels(stats, "direction", "sent")...)
+		w.sample("cachecloud_sync_invalidations_total", formatInt(stats.InvalidationsReceived), bucketLabels(stats, "direction", "received")...)
+	}
+
+	w.header("cachecloud_local_entries", "Number of entries held by the local tier.", "gauge")
+	for _, stats := range all {
+		if stats.BucketType != BucketTypeRedis {
+			w.sample("cachecloud_local_entries", strconv.Itoa(stats.LocalEntries), bucketLabels(stats, "tier", "local")...)
+		}
+	}
+	w.header("cachecloud_local_capacity_bytes", "Capacity in bytes allocated by the local tier, including preallocated space.", "gauge")
+	for _, stats := range all {
+		if stats.BucketType != BucketTypeRedis {
+			w.sample("cachecloud_local_capacity_bytes", strconv.Itoa(stats.LocalCapacityBytes), bucketLabels(stats, "tier", "local")...)
+		}
+	}
+
+	const latencyName = "cachecloud_operation_duration_seconds"
+	w.header(latencyName, "Latency of cache operations.", "histogram")
+	for _, stats := range all {
+		w.writeLatency(latencyName, stats, "get", stats.GetLatency)
+		w.writeLatency(latencyName, stats, "put", stats.PutLatency)
+		w.writeLatency(latencyName, stats, "evict", stats.EvictLatency)
+	}
+	return w.builder.String()
+}
+
+// MetricsHandler 获取输出所有已初始化存储桶监控指标的http.Handler 指标为Prometheus文本格式
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", metricsContentType)
+		_, _ = writer.Write([]byte(writeMetrics(AllStats())))
+	})
+}
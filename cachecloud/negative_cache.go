@@ -0,0 +1,30 @@
+package cachecloud
+
+import (
+	"bytes"
+	"context"
+)
+
+// 否定缓存：supplier未能获取数据时写入"不存在"标记，在较短的过期时间内直接返回 ErrCachedNotFound，避免缓存穿透
+// 标记以固定字节序列作为缓存值存储，首字节为0，不会与gob、json编码的数据冲突，自定义编解码器需避免产生相同的数据
+
+var notFoundMarker = []byte("\x00cachecloud:not-found")
+
+// isNotFoundMarker 缓存值是否为"不存在"标记
+func isNotFoundMarker(data []byte) bool {
+	return bytes.Equal(data, notFoundMarker)
+}
+
+// notFoundCacheBucket 支持写入"不存在"标记的存储桶
+type notFoundCacheBucket interface {
+	// putNotFound 写入"不存在"标记 存储桶未启用否定缓存时不做任何处理
+	putNotFound(ctx context.Context, key CacheKey, keyAppend ...interface{}) error
+}
+
+// putNotFound 向存储桶写入"不存在"标记
+func putNotFound(ctx context.Context, bucket CacheBucket, key CacheKey, keyAppend ...interface{}) error {
+	if b, ok := bucket.(notFoundCacheBucket); ok {
+		return b.putNotFound(ctx, key, keyAppend...)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// 缓存重建请求合并：同一存储桶同一缓存key在同一时刻只有一个supplier调用，其余并发请求等待该调用的结果
+// 等待的请求通过存储桶的编解码器获得结果的独立副本，修改结果不会影响其它请求
+
+var errSupplierPanic = errors.New("supplier panic")
+
+var cacheableFlight = &flightGroup{calls: make(map[string]*flightCall)}
+
+type flightCall struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// flightGroup 合并相同key的并发调用
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do 执行fn 相同key已有进行中的调用时等待其结果 shared 表示结果来自其它调用 等待期间ctx结束时 shared 为false
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (any, error)) (value any, err error, shared bool) {
+	g.mutex.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err, true
+		case <-ctx.Done():
+			return nil, ctx.Err(), false
+		}
+	}
+	call := &flightCall{done: make(chan struct{}), err: errSupplierPanic}
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
+
+// flightResult 重建结果 同时保存编码后的数据供等待的请求解码
+type flightResult[T any] struct {
+	value     *T
+	encoded   []byte
+	encodeErr error
+}
+
+// newFlightResult 编码重建结果 value 不是有效的*T时返回nil
+func newFlightResult[T any](codec Codec, value any) any {
+	v, ok := value.(*T)
+	if !ok || v == nil {
+		return nil
+	}
+	encoded, err := codec.Encode(v)
+	return &flightResult[T]{value: v, encoded: encoded, encodeErr: err}
+}
+
+// load 将重建结果写入result shared 为true时解码独立的副本
+func (r *flightResult[T]) load(codec Codec, result *T, shared bool) error {
+	if !shared {
+		*result = *r.value
+		return nil
+	}
+	if r.encodeErr != nil {
+		return r.encodeErr
+	}
+	return codec.Decode(r.encoded, result)
+}
+
+// flightKey 重建请求合并的key
+func flightKey(bucketName BucketName, rawKey string) string {
+	return string(bucketName) + "\x00" + rawKey
+}
+
+// CoalescedCalls 获取指定存储桶中因并发重建被合并(未实际调用supplier)的请求数 同 BucketStats.CoalescedCalls
+func CoalescedCalls(bucketName BucketName) int64 {
+	stats, err := Stats(bucketName)
+	if err != nil {
+		return 0
+	}
+	return stats.CoalescedCalls
+}
@@ -0,0 +1,51 @@
+package cachecloud
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheableCoalescedCopy(t *testing.T) {
+	useTestMemBuckets(t, NewMemCacheConfig("coalesced", time.Hour))
+	// 存储桶在首次获取时创建 提前获取避免并发创建
+	_ = getBucket("coalesced")
+	cacheKey := CacheKey{KeyFormat: "hot"}
+	release := make(chan struct{})
+	supplier := func() (*[]int, bool) {
+		<-release
+		return &[]int{1, 2, 3}, true
+	}
+
+	const callers = 10
+	results := make([][]int, callers)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Cacheable[[]int]("coalesced", cacheKey, &results[i], supplier); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if CoalescedCalls("coalesced") == 0 {
+		t.Fatal("no coalesced calls")
+	}
+	// 修改任一结果不影响其它请求的结果
+	for i := range results {
+		if len(results[i]) != 3 {
+			t.Fatalf("result %d = %v", i, results[i])
+		}
+		results[i][0] = 100 + i
+	}
+	for i := range results {
+		if results[i][0] != 100+i {
+			t.Errorf("result %d shares storage with another caller: %v", i, results[i])
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package cachecloud
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/allegro/bigcache/v3"
+	"github.com/golang-acexy/starter-redis/redisstarter"
+	"github.com/redis/go-redis/v9"
+)
+
+// 滑动过期：成功获取缓存后将其过期时间按写入时的过期时间重新计算，持续被访问的缓存不会过期
+// 本地缓存距离上次延长超过 slideInterval 时才重新写入，redis缓存的延长在发布端暂存并按 slideInterval 批量发送，同一缓存在一个窗口内仅发送一次
+// 延长后的过期时间不超过写入时的过期时间，"不存在"标记不会被延长；二级缓存的内存缓存在redis缓存延长成功后再延长，不会晚于redis缓存过期
+
+// redisTTLMagic 单独指定过期时间的redis缓存值前附加的头信息标识 其后为13位十进制的过期时间(毫秒)
+// 首字节为0 不会与gob、json编码的数据冲突
+var redisTTLMagic = []byte("\x00cachecloud:ttl=")
+
+const redisTTLHeaderSize = 16 + 13
+
+// redisExtendScript 按写入时的过期时间延长缓存 ARGV: 存储桶的过期时间(毫秒) 头信息标识 "不存在"标记
+// 缓存不存在时返回-1 未延长时返回0 否则返回延长后的过期时间(毫秒)
+var redisExtendScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local head = redis.call('GETRANGE', KEYS[1], 0, #ARGV[2] + 12)
+if head == ARGV[3] then
+	return 0
+end
+local ttl = tonumber(ARGV[1])
+if string.sub(head, 1, #ARGV[2]) == ARGV[2] then
+	ttl = tonumber(string.sub(head, #ARGV[2] + 1))
+end
+if ttl == nil or ttl <= 0 then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ttl)
+return ttl
+`)
+
+// wrapRedisTTL 在缓存值前附加写入时的过期时间
+func wrapRedisTTL(bytes []byte, expire time.Duration) []byte {
+	result := make([]byte, 0, redisTTLHeaderSize+len(bytes))
+	result = append(result, redisTTLMagic...)
+	result = append(result, fmt.Sprintf("%013d", expire.Milliseconds())...)
+	return append(result, bytes...)
+}
+
+// unwrapRedisTTL 去除缓存值前附加的过期时间
+func unwrapRedisTTL(bytes []byte) []byte {
+	if len(bytes) >= redisTTLHeaderSize && string(bytes[:len(redisTTLMagic)]) == string(redisTTLMagic) {
+		return bytes[redisTTLHeaderSize:]
+	}
+	return bytes
+}
+
+func redisExtendArgs(expire time.Duration) []any {
+	return []any{expire.Milliseconds(), string(redisTTLMagic), string(notFoundMarker)}
+}
+
+// extend 按写入时的过期时间延长缓存 距离上次延长不足minInterval时不做处理 limit 大于零时延长后的过期时间不超过limit
+func (l *localCacheBucket) extend(rawKey string, minInterval, limit time.Duration) error {
+	entry, err := l.cache.Get(rawKey)
+	if err != nil {
+		if errors.Is(err, bigcache.ErrEntryNotFound) {
+			return ErrCacheMiss
+		}
+		return err
+	}
+	if len(entry) < localHeaderSize {
+		return ErrCacheMiss
+	}
+	now := time.Now()
+	expireAt := int64(binary.BigEndian.Uint64(entry[:localStaleAtOffset]))
+	if expireAt > 0 && now.UnixNano() >= expireAt {
+		return ErrCacheMiss
+	}
+	if isNotFoundMarker(entry[localHeaderSize:]) {
+		return nil
+	}
+	ttl := time.Duration(binary.BigEndian.Uint64(entry[localTTLOffset:localHeaderSize]))
+	if limit > 0 && (ttl <= 0 || ttl > limit) {
+		ttl = limit
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	// 延长幅度不足minInterval时不做处理 受limit限制时可能缩短
+	target := now.Add(ttl).UnixNano()
+	if diff := time.Duration(target - expireAt); expireAt > 0 && diff >= 0 && diff < minInterval {
+		return nil
+	}
+	binary.BigEndian.PutUint64(entry[:localStaleAtOffset], uint64(target))
+	return l.cache.Set(rawKey, entry)
+}
+
+// slide 存储桶启用滑动过期时延长缓存的过期时间
+func (l *localCacheBucket) slide(rawKey string) {
+	if l.slideInterval > 0 {
+		_ = l.extend(rawKey, l.slideInterval, 0)
+	}
+}
+
+// redisTouchers 启用滑动过期的redis存储桶 *redisToucher -> struct{}
+var redisTouchers sync.Map
+
+// redisToucher 暂存并批量延长redis缓存的过期时间
+type redisToucher struct {
+	interval time.Duration
+	expire   time.Duration
+	onExtend func(rawKey string, ttl time.Duration) // redis缓存延长成功后调用 可为空
+
+	mutex   sync.Mutex
+	pending map[string]struct{}
+	timer   *time.Timer
+}
+
+func newRedisToucher(interval, expire time.Duration) *redisToucher {
+	toucher := &redisToucher{
+		interval: interval,
+		expire:   expire,
+		pending:  make(map[string]struct{}),
+	}
+	redisTouchers.Store(toucher, struct{}{})
+	return toucher
+}
+
+// touch 暂存需要延长过期时间的key
+func (t *redisToucher) touch(rawKey string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pending[rawKey] = struct{}{}
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.interval, func() {
+			_ = t.flush(context.Background())
+		})
+	}
+}
+
+// flush 批量延长暂存的key的过期时间
+func (t *redisToucher) flush(ctx context.Context) error {
+	t.mutex.Lock()
+	pending := t.pending
+	t.pending = make(map[string]struct{})
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.mutex.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	rawKeys := make([]string, 0, len(pending))
+	cmds := make([]*redis.Cmd, 0, len(pending))
+	args := redisExtendArgs(t.expire)
+	_, err := redisstarter.RawRedisClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for rawKey := range pending {
+			rawKeys = append(rawKeys, rawKey)
+			cmds = append(cmds, redisExtendScript.Eval(ctx, pipe, []string{rawKey}, args...))
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Logrus().Warningln("sliding expire failed", len(pending), err)
+	}
+	if t.onExtend != nil {
+		for i, cmd := range cmds {
+			if ttl, e := cmd.Int64(); e == nil && ttl > 0 {
+				t.onExtend(rawKeys[i], time.Duration(ttl)*time.Millisecond)
+			}
+		}
+	}
+	return err
+}
+
+// flushRedisTouchers 立即发送所有存储桶暂存的过期时间延长
+func flushRedisTouchers(ctx context.Context) error {
+	var lastErr error
+	redisTouchers.Range(func(key, _ any) bool {
+		if err := key.(*redisToucher).flush(ctx); err != nil {
+			lastErr = err
+		}
+		return ctx.Err() == nil
+	})
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return lastErr
+}
+
+// slide 存储桶启用滑动过期时延长缓存的过期时间 rawKey 为redis中实际存储的key
+func (m *redisCacheBucket) slide(rawKey string) {
+	if m.toucher != nil {
+		m.toucher.touch(rawKey)
+	}
+}
+
+// extend 立即按写入时的过期时间延长缓存 返回延长后的过期时间 未延长时返回零值
+func (m *redisCacheBucket) extend(ctx context.Context, rawKey string) (time.Duration, error) {
+	ttl, err := redisExtendScript.Run(ctx, redisstarter.RawRedisClient(), []string{m.keyPrefix + rawKey}, redisExtendArgs(m.defaultExpire())...).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, ErrCacheMiss
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// slide 二级缓存的滑动过期 redis缓存需要延长时由 redisToucher 在延长成功后延长内存缓存
+func (m *secondLevelCacheBucket) slide(rawKey string) {
+	if m.redisBucket.toucher != nil {
+		m.redisBucket.slide(m.redisBucket.keyPrefix + rawKey)
+		return
+	}
+	m.memBucket.slide(rawKey)
+}
+
+// touchCacheBucket 支持主动延长过期时间的存储桶
+type touchCacheBucket interface {
+	touch(ctx context.Context, rawKey string) error
+}
+
+// Touch 按缓存写入时的过期时间重新计算过期时间 无论存储桶是否启用滑动过期 缓存不存在时返回 ErrCacheMiss "不存在"标记不会被延长
+// 分布式内存缓存仅延长本实例的本地缓存 二级缓存同时延长redis缓存与本实例的内存缓存
+func Touch(ctx context.Context, bucketName BucketName, cacheKey CacheKey, keyAppend ...interface{}) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	b, ok := bucket.(touchCacheBucket)
+	if !ok {
+		return errors.New("bucket not support touch")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.touch(ctx, cacheKey.RawKeyString(keyAppend...))
+}
+
+func (m *memeCacheBucket) touch(_ context.Context, rawKey string) error {
+	return m.bucket.extend(rawKey, 0, 0)
+}
+
+func (m *distMemeCacheBucket) touch(_ context.Context, rawKey string) error {
+	return m.bucket.extend(rawKey, 0, 0)
+}
+
+func (m *redisCacheBucket) touch(ctx context.Context, rawKey string) error {
+	_, err := m.extend(ctx, rawKey)
+	return err
+}
+
+// touch redis中的缓存不存在时视为缓存不存在 同时清除残留的内存缓存 内存缓存不会晚于redis缓存过期
+func (m *secondLevelCacheBucket) touch(ctx context.Context, rawKey string) error {
+	ttl, err := m.redisBucket.extend(ctx, rawKey)
+	if err != nil {
+		if errors.Is(err, ErrCacheMiss) {
+			_ = m.memBucket.evict(rawKey)
+		}
+		return err
+	}
+	if err := m.memBucket.extend(rawKey, 0, ttl); err != nil && !errors.Is(err, ErrCacheMiss) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package cachecloud
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalExtend(t *testing.T) {
+	local, err := newLocalCacheBucket(NewMemCacheConfig("sliding", time.Hour).WithSlidingExpire(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttlOf := func(rawKey string) time.Duration {
+		meta, _, err := local.localMeta(rawKey)
+		if err != nil {
+			t.Fatalf("localMeta(%s) error = %v", rawKey, err)
+		}
+		return meta.TTL
+	}
+
+	_ = local.putBytes("token", []byte("v"), 300*time.Millisecond)
+	_ = local.putBytes("marker", notFoundMarker, 300*time.Millisecond)
+	_ = local.putBytes("default", []byte("v"), 0)
+	time.Sleep(150 * time.Millisecond)
+
+	if err = local.extend("token", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := ttlOf("token"); ttl <= 200*time.Millisecond || ttl > 300*time.Millisecond {
+		t.Errorf("token ttl = %v, want extended to at most its own 300ms", ttl)
+	}
+	_ = local.extend("marker", 0, 0)
+	if ttl := ttlOf("marker"); ttl > 150*time.Millisecond {
+		t.Errorf("marker ttl = %v, want not extended", ttl)
+	}
+	_ = local.extend("default", 0, time.Minute)
+	if ttl := ttlOf("default"); ttl > time.Minute {
+		t.Errorf("default ttl = %v, want limited to 1m", ttl)
+	}
+	if err = local.extend("missing", 0, 0); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("extend(missing) error = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestRedisTTLHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"json", []byte(`{"name":"acexy"}`)},
+		{"binary", []byte{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := wrapRedisTTL(tt.data, 30*time.Second)
+			if len(wrapped) != redisTTLHeaderSize+len(tt.data) {
+				t.Fatalf("wrapped length = %d", len(wrapped))
+			}
+			if got := string(wrapped[len(redisTTLMagic):redisTTLHeaderSize]); got != "0000000030000" {
+				t.Errorf("ttl digits = %q", got)
+			}
+			if got := unwrapRedisTTL(wrapped); !bytes.Equal(got, tt.data) {
+				t.Errorf("unwrap = %q, want %q", got, tt.data)
+			}
+			if got := unwrapRedisTTL(tt.data); !bytes.Equal(got, tt.data) {
+				t.Errorf("unwrap unwrapped = %q, want unchanged", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cachecloud
+
+import (
+	"context"
+	"sync"
+
+	"github.com/acexy/golang-toolkit/logger"
+)
+
+// 过时数据后台刷新：缓存数据超过 softExpire 后 Cacheable 仍直接返回该数据，同时在后台异步调用一次supplier刷新缓存
+// 同一存储桶同一缓存key同一时刻只有一个后台刷新，超过 hardExpire 后缓存失效，Cacheable 阻塞等待supplier
+
+// staleRefreshing 进行中的后台刷新 flightKey -> struct{}
+var staleRefreshing sync.Map
+
+// staleCacheBucket 支持判断数据是否过时的存储桶
+type staleCacheBucket interface {
+	// staleEnabled 存储桶是否启用了过时数据后台刷新
+	staleEnabled() bool
+	// getStale 获取缓存数据并返回数据是否已过时
+	getStale(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) (bool, error)
+}
+
+// asStaleCacheBucket 获取启用了过时数据后台刷新的存储桶
+func asStaleCacheBucket(bucket CacheBucket) (staleCacheBucket, bool) {
+	b, ok := bucket.(staleCacheBucket)
+	if !ok || !b.staleEnabled() {
+		return nil, false
+	}
+	return b, true
+}
+
+// refreshStale 异步执行refresh 相同key已有进行中的刷新时直接忽略并返回false
+func refreshStale(key string, refresh func() error) bool {
+	if _, loaded := staleRefreshing.LoadOrStore(key, struct{}{}); loaded {
+		return false
+	}
+	go func() {
+		defer staleRefreshing.Delete(key)
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Logrus().Errorln("stale cache refresh panic", key, r)
+			}
+		}()
+		if err := refresh(); err != nil {
+			logger.Logrus().Warningln("stale cache refresh failed", key, err)
+		}
+	}()
+	return true
+}
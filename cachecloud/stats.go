@@ -0,0 +1,375 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 缓存统计：每个存储桶独立维护一组原子计数器与延迟直方图，记录时无需加锁，可在生产环境中持续开启
+// 同名存储桶可能同时存在多种类型，统计数据按 存储桶名+存储桶类型 区分
+
+type statsTier int
+
+const (
+	tierLocal statsTier = iota // 本地内存层
+	tierRedis                  // redis层
+)
+
+// latencyBounds 延迟直方图各区间的上限 最后一个区间无上限
+var latencyBounds = [...]time.Duration{
+	50 * time.Microsecond, 100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second,
+}
+
+type statsKey struct {
+	bucketName BucketName
+	bucketType BucketType
+}
+
+// bucketStatsRegistry 所有存储桶的统计数据 statsKey -> *bucketStats
+var bucketStatsRegistry sync.Map
+
+// TierStats 单个缓存层的统计数据
+type TierStats struct {
+	Hits   int64 // 命中次数(包含命中"不存在"标记)
+	Misses int64 // 未命中次数
+	Errors int64 // 错误次数 不包含ctx取消与超时
+}
+
+// LatencyStats 延迟直方图
+type LatencyStats struct {
+	Bounds []time.Duration // 各区间的上限 最后一个区间无上限
+	Counts []int64         // 各区间的次数(非累计) 长度为 len(Bounds)+1
+	Count  int64           // 总次数
+	Sum    time.Duration   // 总耗时
+}
+
+// BucketStats 存储桶的统计数据
+type BucketStats struct {
+	BucketName BucketName
+	BucketType BucketType
+
+	Local TierStats // 本地内存层 redis存储桶中始终为零值
+	Redis TierStats // redis层 内存存储桶中仅记录同步消息的发布失败
+
+	Misses       int64 // 所有缓存层均未命中的次数
+	NegativeHits int64 // 命中"不存在"标记的次数
+	Puts         int64 // 成功写入的次数
+	Evictions    int64 // 成功清除的次数
+	Errors       int64 // 读写及清除操作返回错误的次数
+
+	SupplierCalls  int64 // Cacheable 调用supplier的次数
+	CoalescedCalls int64 // Cacheable 因并发重建被合并(未实际调用supplier)的次数
+	StaleRefreshes int64 // Cacheable 返回过时数据并触发后台刷新的次数
+
+	InvalidationsSent     int64 // 向其它实例发送的缓存变化数
+	InvalidationsReceived int64 // 从其它实例接收的缓存变化数
+
+	LocalEntries       int // 本地内存层当前的缓存数量
+	LocalCapacityBytes int // 本地内存层已分配的容量(字节) 包含预分配未使用的空间 并非缓存数据实际占用的大小
+
+	GetLatency   LatencyStats // 获取缓存的耗时 批量操作按一次计入
+	PutLatency   LatencyStats // 写入缓存的耗时 批量操作按一次计入
+	EvictLatency LatencyStats // 清除缓存的耗时 批量操作按一次计入
+}
+
+type tierCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+func (t *tierCounters) snapshot() TierStats {
+	return TierStats{Hits: t.hits.Load(), Misses: t.misses.Load(), Errors: t.errors.Load()}
+}
+
+type latencyHistogram struct {
+	counts [len(latencyBounds) + 1]atomic.Int64
+	count  atomic.Int64
+	sum    atomic.Int64
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *latencyHistogram) snapshot() LatencyStats {
+	counts := make([]int64, len(h.counts))
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+	}
+	return LatencyStats{
+		Bounds: latencyBounds[:],
+		Counts: counts,
+		Count:  h.count.Load(),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+}
+
+// bucketStats 存储桶的统计计数器 nil时不做任何记录
+type bucketStats struct {
+	key   statsKey
+	local *localCacheBucket
+
+	localTier tierCounters
+	redisTier tierCounters
+
+	misses       atomic.Int64
+	negativeHits atomic.Int64
+	puts         atomic.Int64
+	evictions    atomic.Int64
+	errors       atomic.Int64
+
+	supplierCalls  atomic.Int64
+	coalescedCalls atomic.Int64
+	staleRefreshes atomic.Int64
+
+	invalidationsSent     atomic.Int64
+	invalidationsReceived atomic.Int64
+
+	getLatency   latencyHistogram
+	putLatency   latencyHistogram
+	evictLatency latencyHistogram
+}
+
+// bucketStatsOf 获取存储桶的统计计数器 不存在时创建
+func bucketStatsOf(bucketName BucketName, bucketType BucketType) *bucketStats {
+	key := statsKey{bucketName: bucketName, bucketType: bucketType}
+	if stats, ok := bucketStatsRegistry.Load(key); ok {
+		return stats.(*bucketStats)
+	}
+	stats, _ := bucketStatsRegistry.LoadOrStore(key, &bucketStats{key: key})
+	return stats.(*bucketStats)
+}
+
+// statsCacheBucket 维护统计数据的存储桶
+type statsCacheBucket interface {
+	statsCounter() *bucketStats
+}
+
+// statsOf 获取存储桶实例的统计计数器
+func statsOf(bucket CacheBucket) *bucketStats {
+	if b, ok := bucket.(statsCacheBucket); ok {
+		return b.statsCounter()
+	}
+	return nil
+}
+
+func (s *bucketStats) tier(tier statsTier) *tierCounters {
+	if tier == tierRedis {
+		return &s.redisTier
+	}
+	return &s.localTier
+}
+
+// recordTier 记录单个缓存层的读取结果
+func (s *bucketStats) recordTier(tier statsTier, err error) {
+	if s == nil {
+		return
+	}
+	t := s.tier(tier)
+	switch {
+	case err == nil || errors.Is(err, ErrCachedNotFound):
+		t.hits.Add(1)
+	case errors.Is(err, ErrCacheMiss):
+		t.misses.Add(1)
+	default:
+		s.recordTierError(tier, err)
+	}
+}
+
+// recordTierError 记录缓存层的错误 ctx取消与超时不计入
+func (s *bucketStats) recordTierError(tier statsTier, err error) {
+	if s == nil || err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	s.tier(tier).errors.Add(1)
+}
+
+// recordHits 记录批量读取中单个缓存层的命中情况
+func (s *bucketStats) recordHits(tier statsTier, hits []bool) {
+	if s == nil {
+		return
+	}
+	t := s.tier(tier)
+	for _, hit := range hits {
+		if hit {
+			t.hits.Add(1)
+		} else {
+			t.misses.Add(1)
+		}
+	}
+}
+
+// recordGet 记录一次获取操作 tier 为最终返回结果的缓存层
+func (s *bucketStats) recordGet(tier statsTier, err error, start time.Time) {
+	if s == nil {
+		return
+	}
+	s.recordTier(tier, err)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrCacheMiss):
+		s.misses.Add(1)
+	case errors.Is(err, ErrCachedNotFound):
+		s.negativeHits.Add(1)
+	default:
+		s.errors.Add(1)
+	}
+	s.getLatency.observe(time.Since(start))
+}
+
+// recordMGet 记录一次批量获取操作
+func (s *bucketStats) recordMGet(hits []bool, err error, start time.Time) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.errors.Add(1)
+	}
+	for _, hit := range hits {
+		if !hit {
+			s.misses.Add(1)
+		}
+	}
+	s.getLatency.observe(time.Since(start))
+}
+
+// recordPut 记录写入操作 count 为写入成功的缓存数量
+func (s *bucketStats) recordPut(count int, err error, start time.Time) {
+	if s == nil {
+		return
+	}
+	s.puts.Add(int64(count))
+	if err != nil {
+		s.errors.Add(1)
+	}
+	s.putLatency.observe(time.Since(start))
+}
+
+// recordEvict 记录清除操作 count 为清除的缓存数量 缓存不存在不视为错误
+func (s *bucketStats) recordEvict(count int, err error, start time.Time) {
+	if s == nil {
+		return
+	}
+	if err == nil {
+		s.evictions.Add(int64(count))
+	} else if !errors.Is(err, ErrCacheMiss) {
+		s.errors.Add(1)
+	}
+	s.evictLatency.observe(time.Since(start))
+}
+
+// recordSent 记录同步消息的发送结果
+func (s *bucketStats) recordSent(count int, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.recordTierError(tierRedis, err)
+		return
+	}
+	s.invalidationsSent.Add(int64(count))
+}
+
+func (s *bucketStats) recordReceived(count int) {
+	if s == nil {
+		return
+	}
+	s.invalidationsReceived.Add(int64(count))
+}
+
+func (s *bucketStats) addSupplierCall() {
+	if s != nil {
+		s.supplierCalls.Add(1)
+	}
+}
+
+func (s *bucketStats) addCoalesced() {
+	if s != nil {
+		s.coalescedCalls.Add(1)
+	}
+}
+
+func (s *bucketStats) addStaleRefresh() {
+	if s != nil {
+		s.staleRefreshes.Add(1)
+	}
+}
+
+func (s *bucketStats) snapshot() BucketStats {
+	result := BucketStats{
+		BucketName: s.key.bucketName,
+		BucketType: s.key.bucketType,
+
+		Local: s.localTier.snapshot(),
+		Redis: s.redisTier.snapshot(),
+
+		Misses:       s.misses.Load(),
+		NegativeHits: s.negativeHits.Load(),
+		Puts:         s.puts.Load(),
+		Evictions:    s.evictions.Load(),
+		Errors:       s.errors.Load(),
+
+		SupplierCalls:  s.supplierCalls.Load(),
+		CoalescedCalls: s.coalescedCalls.Load(),
+		StaleRefreshes: s.staleRefreshes.Load(),
+
+		InvalidationsSent:     s.invalidationsSent.Load(),
+		InvalidationsReceived: s.invalidationsReceived.Load(),
+
+		GetLatency:   s.getLatency.snapshot(),
+		PutLatency:   s.putLatency.snapshot(),
+		EvictLatency: s.evictLatency.snapshot(),
+	}
+	if s.local != nil {
+		result.LocalEntries = s.local.cache.Len()
+		result.LocalCapacityBytes = s.local.cache.Capacity()
+	}
+	return result
+}
+
+// Stats 获取存储桶的统计数据 同名存储桶存在多种类型时与 GetBucket 的匹配规则一致
+func Stats(bucketName BucketName) (BucketStats, error) {
+	s := statsOf(getBucket(bucketName))
+	if s == nil {
+		return BucketStats{}, errors.New("bucket not found")
+	}
+	return s.snapshot(), nil
+}
+
+// AllStats 获取所有已初始化存储桶的统计数据 按存储桶名和类型排序
+func AllStats() []BucketStats {
+	var result []BucketStats
+	bucketStatsRegistry.Range(func(_, value any) bool {
+		result = append(result, value.(*bucketStats).snapshot())
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BucketName != result[j].BucketName {
+			return result[i].BucketName < result[j].BucketName
+		}
+		return result[i].BucketType < result[j].BucketType
+	})
+	return result
+}
+
+// successCount 单个操作成功时返回1
+func successCount(err error) int {
+	if err == nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package cachecloud
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatsUnknownBucket(t *testing.T) {
+	redisCache = &redisCacheManager{buckets: map[BucketName]*redisCacheBucket{
+		"known": newRedisCacheBucket(redisBucketKeyPrefix("known"), NewRedisCacheConfig("known", time.Hour)),
+	}}
+	useRedisCache = true
+	defer func() {
+		redisCache, useRedisCache = nil, false
+	}()
+
+	if _, err := Stats("known"); err != nil {
+		t.Fatalf("Stats(known) error = %v", err)
+	}
+	if _, err := Stats("unknown"); err == nil {
+		t.Fatal("Stats(unknown) error = nil, want bucket not found")
+	}
+	if err := Touch(context.Background(), "unknown", CacheKey{KeyFormat: "k"}); err == nil {
+		t.Fatal("Touch(unknown) error = nil, want bucket not found")
+	}
+}
@@ -0,0 +1,175 @@
+package cachecloud
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 同步消息合并发布：启用后存储桶的缓存变化事件先在发布端暂存，达到批次大小或时间窗口后合并为少量同步消息发布
+// 同一缓存key的多次变化仅发布最后一次，清空事件将丢弃此前暂存的所有变化，其它实例最多延迟一个时间窗口感知缓存变化
+// 事件按发生的先后顺序发布，达到批次大小时由后台按顺序发布，不阻塞写入缓存的调用方
+
+const defaultSyncBatchSize = 1000
+
+// syncBatchers 启用合并发布的存储桶 同步主题+存储桶名 -> *syncBatcher
+var syncBatchers sync.Map
+
+// syncBatcher 单个存储桶暂存的缓存变化事件
+type syncBatcher struct {
+	topicName  string
+	bucketName string
+	window     time.Duration
+	maxSize    int
+	stats      *bucketStats
+
+	mutex   sync.Mutex
+	pending []syncEntry
+	index   map[syncBatchKey]int // 缓存key -> pending中最后一次变化的位置
+	timer   *time.Timer
+	queue   [][]syncEntry // 已取出等待发布的批次
+
+	publishMutex sync.Mutex // 保证批次按取出的顺序发布
+}
+
+// syncBatchKey 合并发布时去重的依据 缓存key格式与缓存key分别去重
+type syncBatchKey struct {
+	format bool
+	rawKey string
+}
+
+func syncBatchKeyOf(entry syncEntry) syncBatchKey {
+	return syncBatchKey{format: entry.typ == syncTypeFormat, rawKey: entry.rawKey}
+}
+
+// publicSyncEvent 发布存储桶的缓存变化事件 存储桶启用合并发布时暂存
+func publicSyncEvent(ctx context.Context, topicName, bucketName string, local *localCacheBucket, entries ...syncEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	if local.batchWindow <= 0 {
+		local.stats.recordSent(len(entries), publishSyncMessage(ctx, topicName, bucketName, entries...))
+		return
+	}
+	key := topicName + topicDelimiter + bucketName
+	value, ok := syncBatchers.Load(key)
+	if !ok {
+		value, _ = syncBatchers.LoadOrStore(key, &syncBatcher{
+			topicName:  topicName,
+			bucketName: bucketName,
+			window:     local.batchWindow,
+			maxSize:    local.batchSize,
+			stats:      local.stats,
+			index:      make(map[syncBatchKey]int),
+		})
+	}
+	value.(*syncBatcher).add(entries...)
+}
+
+// add 暂存缓存变化事件 达到批次大小时交由后台发布
+func (b *syncBatcher) add(entries ...syncEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, v := range entries {
+		if v.typ == syncTypeFlush {
+			b.pending = append(b.pending[:0], v)
+			clear(b.index)
+			continue
+		}
+		// 同一缓存key仅保留最后一次变化 并按最后一次变化的顺序发布 此前的变化在取出时丢弃
+		b.index[syncBatchKeyOf(v)] = len(b.pending)
+		b.pending = append(b.pending, v)
+	}
+	if len(b.pending) > 2*b.maxSize {
+		b.pending = b.compact()
+	}
+	if len(b.index) < b.maxSize {
+		if b.timer == nil && len(b.pending) > 0 {
+			b.timer = time.AfterFunc(b.window, func() {
+				_ = b.flush(context.Background())
+			})
+		}
+		return
+	}
+	b.enqueue()
+	go func() {
+		_ = b.drain(context.Background())
+	}()
+}
+
+// compact 丢弃已被后续变化覆盖的事件 调用方需持有锁
+func (b *syncBatcher) compact() []syncEntry {
+	batch := make([]syncEntry, 0, len(b.index)+1)
+	for i, v := range b.pending {
+		if v.typ == syncTypeFlush || b.index[syncBatchKeyOf(v)] == i {
+			if v.typ != syncTypeFlush {
+				b.index[syncBatchKeyOf(v)] = len(batch)
+			}
+			batch = append(batch, v)
+		}
+	}
+	return batch
+}
+
+// enqueue 取出所有暂存的缓存变化事件并加入发布队列 调用方需持有锁
+func (b *syncBatcher) enqueue() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if batch := b.compact(); len(batch) > 0 {
+		b.queue = append(b.queue, batch)
+	}
+	b.pending = nil
+	b.index = make(map[syncBatchKey]int)
+}
+
+// drain 按顺序发布队列中的所有批次
+func (b *syncBatcher) drain(ctx context.Context) error {
+	b.publishMutex.Lock()
+	defer b.publishMutex.Unlock()
+	var lastErr error
+	for {
+		b.mutex.Lock()
+		if len(b.queue) == 0 {
+			b.mutex.Unlock()
+			return lastErr
+		}
+		batch := b.queue[0]
+		b.queue = b.queue[1:]
+		b.mutex.Unlock()
+		if err := b.publish(ctx, batch); err != nil {
+			lastErr = err
+		}
+	}
+}
+
+// flush 立即发布所有暂存的缓存变化事件
+func (b *syncBatcher) flush(ctx context.Context) error {
+	b.mutex.Lock()
+	b.enqueue()
+	b.mutex.Unlock()
+	return b.drain(ctx)
+}
+
+// publish 发布一个批次 批次内保持事件的先后顺序
+func (b *syncBatcher) publish(ctx context.Context, batch []syncEntry) error {
+	err := publishSyncMessage(ctx, b.topicName, b.bucketName, batch...)
+	b.stats.recordSent(len(batch), err)
+	return err
+}
+
+// flushSyncBatchers 发布所有存储桶暂存的缓存变化事件
+func flushSyncBatchers(ctx context.Context) error {
+	var lastErr error
+	syncBatchers.Range(func(_, value any) bool {
+		if err := value.(*syncBatcher).flush(ctx); err != nil {
+			lastErr = err
+		}
+		return ctx.Err() == nil
+	})
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return lastErr
+}
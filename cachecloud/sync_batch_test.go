@@ -0,0 +1,114 @@
+package cachecloud
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestSyncBatcher(maxSize int) *syncBatcher {
+	return &syncBatcher{
+		topicName:  "test-sync-batch",
+		bucketName: "bucket",
+		window:     time.Hour,
+		maxSize:    maxSize,
+		stats:      bucketStatsOf("test-sync-batch", BucketTypeDistMem),
+		index:      make(map[syncBatchKey]int),
+	}
+}
+
+func TestSyncBatcherCompact(t *testing.T) {
+	changedA1 := newChangedSyncEntry("a", []byte("1"), 0)
+	changedA2 := newChangedSyncEntry("a", []byte("2"), 0)
+	deletedA := newDeletedSyncEntry("a")
+	deletedB := newDeletedSyncEntry("b")
+	deletedC := newDeletedSyncEntry("c")
+	formatA := newFormatSyncEntry("a")
+	flush := newFlushSyncEntry()
+	tests := []struct {
+		name    string
+		entries []syncEntry
+		want    []syncEntry
+	}{
+		{"keep order", []syncEntry{deletedB, changedA1, deletedC}, []syncEntry{deletedB, changedA1, deletedC}},
+		{"latest wins", []syncEntry{changedA1, deletedB, changedA2}, []syncEntry{deletedB, changedA2}},
+		{"changed then deleted", []syncEntry{changedA1, deletedA}, []syncEntry{deletedA}},
+		{"format separate from key", []syncEntry{changedA1, formatA, deletedB}, []syncEntry{changedA1, formatA, deletedB}},
+		{"flush discards earlier", []syncEntry{changedA1, deletedB, flush, deletedC, changedA2}, []syncEntry{flush, deletedC, changedA2}},
+		{"repeated flush", []syncEntry{flush, changedA1, flush}, []syncEntry{flush}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestSyncBatcher(100)
+			b.add(tt.entries...)
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			b.timer.Stop()
+			if got := b.compact(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compact = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncBatcherCompactOverflow(t *testing.T) {
+	b := newTestSyncBatcher(2)
+	// 同一缓存key反复变化时暂存数量超过批次大小的两倍后就地压缩 不触发发布
+	for i := 0; i < 10; i++ {
+		b.add(newChangedSyncEntry("a", []byte{byte(i)}, 0))
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.timer.Stop()
+	if len(b.pending) > 2*b.maxSize || len(b.queue) != 0 {
+		t.Fatalf("pending = %d, queue = %d", len(b.pending), len(b.queue))
+	}
+	want := []syncEntry{newChangedSyncEntry("a", []byte{9}, 0)}
+	if got := b.compact(); !reflect.DeepEqual(got, want) {
+		t.Errorf("compact = %+v, want %+v", got, want)
+	}
+}
+
+func TestSyncBatcherPublishOrder(t *testing.T) {
+	transport := NewLoopbackTransport()
+	original := syncTransport
+	syncTransport = transport
+	t.Cleanup(func() { syncTransport = original })
+
+	received := make(chan syncEntry, 16)
+	_ = transport.Subscribe(context.Background(), "test-sync-batch", func(payload string) {
+		_, _, entries, ok := decodeSyncMessage(payload)
+		if !ok {
+			t.Errorf("invalid payload %s", payload)
+			return
+		}
+		for _, v := range entries {
+			received <- v
+		}
+	})
+
+	b := newTestSyncBatcher(2)
+	// 第二个不同的缓存key达到批次大小 由后台发布
+	b.add(newDeletedSyncEntry("a"), newDeletedSyncEntry("a"), newDeletedSyncEntry("b"))
+	b.add(newChangedSyncEntry("c", []byte("1"), 0))
+	if err := b.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []syncEntry{newDeletedSyncEntry("a"), newDeletedSyncEntry("b"), newChangedSyncEntry("c", []byte("1"), 0)}
+	for i, v := range want {
+		select {
+		case got := <-received:
+			if !reflect.DeepEqual(got, v) {
+				t.Errorf("entry %d = %+v, want %+v", i, got, v)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("entry %d not published", i)
+		}
+	}
+	select {
+	case got := <-received:
+		t.Errorf("unexpected entry %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
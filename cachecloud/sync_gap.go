@@ -0,0 +1,141 @@
+package cachecloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+)
+
+// 同步消息丢失处理：同步传输断线重连或检测到消息被裁剪后，期间其它实例发布的缓存变化事件可能已丢失
+// 此时按存储桶配置的策略处理本地缓存，并通过 Option.OnSyncGap 通知
+
+// SyncGapAction 同步消息可能丢失后本地缓存的处理策略
+type SyncGapAction string
+
+const (
+	SyncGapFlush      SyncGapAction = "flush"      // 清空本地缓存 默认策略
+	SyncGapShorten    SyncGapAction = "shorten"    // 缩短本地缓存的剩余过期时间
+	SyncGapRevalidate SyncGapAction = "revalidate" // 标记本地缓存为过时 Cacheable 仍返回该数据并在后台刷新
+)
+
+var syncGapHook func(event SyncGapEvent)
+
+// SyncGapEvent 同步消息可能丢失的事件 每个受影响的存储桶触发一次
+type SyncGapEvent struct {
+	Topic      string
+	BucketName BucketName
+	BucketType BucketType
+	Action     SyncGapAction
+	Entries    int // 受影响的本地缓存数量 清空时为清空前的数量
+	Err        error
+}
+
+// syncGapAction 获取生效的处理策略
+func (l *localCacheBucket) syncGapAction() SyncGapAction {
+	switch l.gapAction {
+	case SyncGapShorten:
+		if l.gapTTL > 0 {
+			return SyncGapShorten
+		}
+	case SyncGapRevalidate:
+		return SyncGapRevalidate
+	}
+	return SyncGapFlush
+}
+
+// handleSyncGap 按存储桶配置的策略处理本地缓存
+func (l *localCacheBucket) handleSyncGap(tag, topicName string) {
+	event := SyncGapEvent{
+		Topic:      topicName,
+		BucketName: l.stats.key.bucketName,
+		BucketType: l.stats.key.bucketType,
+		Action:     l.syncGapAction(),
+	}
+	now := time.Now().UnixNano()
+	switch event.Action {
+	case SyncGapShorten:
+		maxExpireAt := time.Now().Add(l.gapTTL).UnixNano()
+		event.Entries = l.rewriteEntries(func(expireAt, staleAt int64) (int64, int64) {
+			return min(expireAt, maxExpireAt), staleAt
+		})
+	case SyncGapRevalidate:
+		event.Entries = l.rewriteEntries(func(expireAt, staleAt int64) (int64, int64) {
+			if staleAt == 0 || staleAt > now {
+				staleAt = now
+			}
+			return expireAt, staleAt
+		})
+	default:
+		event.Entries = l.cache.Len()
+		event.Err = l.clear()
+	}
+	if event.Err != nil {
+		logger.Logrus().Warningln(tag, "sync gap handle failed", event.BucketName, event.Action, event.Err)
+	} else {
+		logger.Logrus().Infoln(tag, "sync gap handled", event.BucketName, event.Action, event.Entries)
+	}
+	if syncGapHook != nil {
+		syncGapHook(event)
+	}
+}
+
+// rewriteEntries 改写本地缓存中所有数据的过期时间与过时时间戳 返回改写的数据数量
+// 改写期间被重新写入的数据保持不变
+func (l *localCacheBucket) rewriteEntries(rewrite func(expireAt, staleAt int64) (int64, int64)) int {
+	type localEntry struct {
+		rawKey   string
+		original []byte
+		entry    []byte
+	}
+	now := time.Now().UnixNano()
+	var entries []localEntry
+	iterator := l.cache.Iterator()
+	for iterator.SetNext() {
+		info, err := iterator.Value()
+		if err != nil || len(info.Value()) < localHeaderSize {
+			continue
+		}
+		original := info.Value()
+		expireAt := int64(binary.BigEndian.Uint64(original[:localStaleAtOffset]))
+		if expireAt == 0 {
+			if l.expire > 0 {
+				// 未单独指定过期时间的数据由bigcache按写入时间淘汰 重新写入前需保留原本的过期时间
+				expireAt = time.Unix(int64(info.Timestamp()), 0).Add(l.expire).UnixNano()
+			} else {
+				// 不过期的数据视为无限远的过期时间 改写后仍无限远时保持不过期
+				expireAt = math.MaxInt64
+			}
+		}
+		if expireAt <= now {
+			continue
+		}
+		staleAt := int64(binary.BigEndian.Uint64(original[localStaleAtOffset:localTTLOffset]))
+		newExpireAt, staleAt := rewrite(expireAt, staleAt)
+		entry := make([]byte, len(original))
+		copy(entry, original)
+		if newExpireAt < expireAt {
+			// 缩短后滑动过期同样不超过缩短后的过期时间
+			binary.BigEndian.PutUint64(entry[localTTLOffset:localHeaderSize], uint64(newExpireAt-now))
+		}
+		if newExpireAt == math.MaxInt64 {
+			newExpireAt = 0
+		}
+		binary.BigEndian.PutUint64(entry[:localStaleAtOffset], uint64(newExpireAt))
+		binary.BigEndian.PutUint64(entry[localStaleAtOffset:localTTLOffset], uint64(staleAt))
+		entries = append(entries, localEntry{rawKey: info.Key(), original: original, entry: entry})
+	}
+	count := 0
+	for _, v := range entries {
+		current, err := l.cache.Get(v.rawKey)
+		if err != nil || !bytes.Equal(current, v.original) {
+			continue
+		}
+		if l.cache.Set(v.rawKey, v.entry) == nil {
+			count++
+		}
+	}
+	return count
+}
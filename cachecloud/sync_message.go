@@ -0,0 +1,266 @@
+package cachecloud
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/acexy/golang-toolkit/crypto/hashing"
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/acexy/golang-toolkit/util/json"
+)
+
+// 缓存同步消息格式(v2)：json对象 {"v":2,"t":消息类型,"n":节点ID,"b":存储桶名,"ts":毫秒时间戳,"e":[{"k":缓存key,"s":数据摘要,"ttl":毫秒过期时间}]}
+// 字段均经过json转义，缓存key中包含任意字符都不会影响解析
+// 旧版格式：节点ID<@.>存储桶名<@.>缓存key<@.>数据摘要[<@.>缓存key<@.>数据摘要...]，数据摘要为空表示删除，缓存key为空且数据摘要为 syncFlushSum 表示清空
+// 接收时两种格式均可解析，滚动升级期间可通过 Option.LegacySyncMessage 继续发布旧版格式
+
+const (
+	syncMessageVersion = 2
+	syncFlushSum       = "*"
+)
+
+// syncType 同步消息类型
+type syncType string
+
+const (
+	syncTypeChanged syncType = "changed"
+	syncTypeDeleted syncType = "deleted"
+	syncTypeFlush   syncType = "flush"
+	syncTypeFormat  syncType = "format" // 清除由缓存key格式生成的所有缓存 rawKey 为缓存key格式
+)
+
+var legacySyncMessage bool
+
+// syncEntry 同步消息中单个缓存key的变化
+type syncEntry struct {
+	typ    syncType
+	rawKey string
+	sum    string        // 数据摘要 仅缓存数据发生变化时存在
+	ttl    time.Duration // 缓存数据的过期时间 零值表示存储桶默认的过期时间
+}
+
+// syncMessage v2同步消息
+type syncMessage struct {
+	Version   int                `json:"v"`
+	Type      syncType           `json:"t"`
+	NodeId    string             `json:"n"`
+	Bucket    string             `json:"b"`
+	Timestamp int64              `json:"ts"`
+	Entries   []syncMessageEntry `json:"e,omitempty"`
+}
+
+type syncMessageEntry struct {
+	Key string `json:"k"`
+	Sum string `json:"s,omitempty"`
+	TTL int64  `json:"ttl,omitempty"`
+}
+
+// dataSum 计算缓存数据摘要
+func dataSum(bytes []byte) string {
+	md5Bytes := hashing.Md5Bytes(bytes)
+	return hex.EncodeToString(md5Bytes[:])
+}
+
+// newChangedSyncEntry 缓存数据发生变化
+func newChangedSyncEntry(rawKey string, bytes []byte, ttl time.Duration) syncEntry {
+	return syncEntry{typ: syncTypeChanged, rawKey: rawKey, sum: dataSum(bytes), ttl: ttl}
+}
+
+// newDeletedSyncEntry 缓存数据被删除
+func newDeletedSyncEntry(rawKey string) syncEntry {
+	return syncEntry{typ: syncTypeDeleted, rawKey: rawKey}
+}
+
+// newFormatSyncEntry 清除由缓存key格式生成的所有缓存
+func newFormatSyncEntry(format string) syncEntry {
+	return syncEntry{typ: syncTypeFormat, rawKey: format}
+}
+
+// newFlushSyncEntry 清空存储桶
+func newFlushSyncEntry() syncEntry {
+	return syncEntry{typ: syncTypeFlush}
+}
+
+// encodeSyncMessage 编码同步消息 entries 需为同一消息类型
+func encodeSyncMessage(bucketName string, entries ...syncEntry) (string, error) {
+	if legacySyncMessage {
+		return encodeLegacySyncMessage(bucketName, entries...), nil
+	}
+	message := syncMessage{
+		Version:   syncMessageVersion,
+		Type:      entries[0].typ,
+		NodeId:    getNodeId(),
+		Bucket:    bucketName,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if message.Type != syncTypeFlush {
+		message.Entries = make([]syncMessageEntry, len(entries))
+		for i, v := range entries {
+			message.Entries[i] = syncMessageEntry{Key: v.rawKey, Sum: v.sum, TTL: v.ttl.Milliseconds()}
+		}
+	}
+	bytes, err := json.ToBytesError(message)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// encodeLegacySyncMessage 编码旧版同步消息 缓存key包含分隔符时无法被正确解析，此时改为清空存储桶
+func encodeLegacySyncMessage(bucketName string, entries ...syncEntry) string {
+	var builder strings.Builder
+	builder.WriteString(getNodeId())
+	builder.WriteString(topicDelimiter)
+	builder.WriteString(bucketName)
+	for _, v := range entries {
+		rawKey, sum := v.rawKey, v.sum
+		// 旧版格式不支持按缓存key格式清除
+		if v.typ == syncTypeFlush || v.typ == syncTypeFormat || strings.Contains(rawKey, topicDelimiter) {
+			rawKey, sum = "", syncFlushSum
+		}
+		builder.WriteString(topicDelimiter)
+		builder.WriteString(rawKey)
+		builder.WriteString(topicDelimiter)
+		builder.WriteString(sum)
+	}
+	return builder.String()
+}
+
+// decodeSyncMessage 解析同步消息 兼容旧版格式 格式错误时返回false
+func decodeSyncMessage(payload string) (nodeId, bucketName string, entries []syncEntry, ok bool) {
+	if !strings.HasPrefix(payload, "{") {
+		return decodeLegacySyncMessage(payload)
+	}
+	var message syncMessage
+	if json.ParseBytesError([]byte(payload), &message) != nil {
+		return "", "", nil, false
+	}
+	if message.Version != syncMessageVersion || message.NodeId == "" || message.Bucket == "" {
+		return "", "", nil, false
+	}
+	switch message.Type {
+	case syncTypeFlush:
+		return message.NodeId, message.Bucket, []syncEntry{newFlushSyncEntry()}, true
+	case syncTypeChanged, syncTypeDeleted, syncTypeFormat:
+	default:
+		return "", "", nil, false
+	}
+	if len(message.Entries) == 0 {
+		return "", "", nil, false
+	}
+	entries = make([]syncEntry, len(message.Entries))
+	for i, v := range message.Entries {
+		if message.Type == syncTypeChanged && v.Sum == "" {
+			return "", "", nil, false
+		}
+		entries[i] = syncEntry{typ: message.Type, rawKey: v.Key, sum: v.Sum, ttl: time.Duration(v.TTL) * time.Millisecond}
+	}
+	return message.NodeId, message.Bucket, entries, true
+}
+
+func decodeLegacySyncMessage(payload string) (nodeId, bucketName string, entries []syncEntry, ok bool) {
+	split := strings.Split(payload, topicDelimiter)
+	if len(split) < 4 || len(split)%2 != 0 || split[0] == "" || split[1] == "" {
+		return "", "", nil, false
+	}
+	entries = make([]syncEntry, 0, len(split)/2-1)
+	for i := 2; i < len(split); i += 2 {
+		switch {
+		case split[i] == "" && split[i+1] == syncFlushSum:
+			entries = append(entries, newFlushSyncEntry())
+		case split[i+1] == "":
+			entries = append(entries, newDeletedSyncEntry(split[i]))
+		default:
+			entries = append(entries, syncEntry{typ: syncTypeChanged, rawKey: split[i], sum: split[i+1]})
+		}
+	}
+	return split[0], split[1], entries, true
+}
+
+// publishSyncMessage 向其它实例发布缓存变化事件 不同类型的变化分别发布 发布失败时记录日志并返回错误
+func publishSyncMessage(ctx context.Context, topicName, bucketName string, entries ...syncEntry) error {
+	var lastErr error
+	for start := 0; start < len(entries); {
+		end := start + 1
+		for end < len(entries) && entries[end].typ == entries[start].typ {
+			end++
+		}
+		payload, err := encodeSyncMessage(bucketName, entries[start:end]...)
+		if err == nil {
+			err = syncTransport.Publish(ctx, topicName, payload)
+		}
+		if err != nil {
+			logger.Logrus().Warningln("event publish failed", bucketName, end-start, err)
+			lastErr = err
+		}
+		start = end
+	}
+	return lastErr
+}
+
+// subscribeSyncMessage 订阅其它实例发布的缓存变化事件 同步消息可能丢失时按存储桶配置的策略处理本地缓存
+func subscribeSyncMessage(tag, topicName string, locals map[string]*localCacheBucket) {
+	if notifier, ok := syncTransport.(SyncGapNotifier); ok {
+		notifier.NotifyGap(topicName, func() {
+			for _, bucket := range locals {
+				bucket.handleSyncGap(tag, topicName)
+			}
+		})
+	}
+	err := syncTransport.Subscribe(context.Background(), topicName, func(payload string) {
+		handleSyncMessage(tag, payload, locals)
+	})
+	if err != nil {
+		logger.Logrus().Errorln(tag, "subscribe failed", err)
+	}
+}
+
+// handleSyncMessage 处理其它实例发布的缓存变化事件
+func handleSyncMessage(tag, payload string, locals map[string]*localCacheBucket) {
+	nodeId, bucketName, entries, ok := decodeSyncMessage(payload)
+	if !ok {
+		logger.Logrus().Warningln(tag, "bad sync message", payload)
+		return
+	}
+	if nodeId == getNodeId() {
+		return
+	}
+	bucket := locals[bucketName]
+	if bucket == nil {
+		return
+	}
+	bucket.stats.recordReceived(len(entries))
+	var traceKey string
+	if len(entries) == 1 {
+		traceKey = entries[0].rawKey
+	}
+	_, span := startSpan(context.Background(), TraceOpSync, bucket.stats, traceKey)
+	defer endSpan(span, SpanEnd{})
+	for _, v := range entries {
+		switch v.typ {
+		case syncTypeFlush:
+			if bucket.clear() == nil {
+				logger.Logrus().Traceln(tag, "flushed", bucketName)
+			}
+		case syncTypeDeleted:
+			if bucket.evict(v.rawKey) == nil {
+				logger.Logrus().Traceln(tag, "deleted", bucketName, v.rawKey)
+			}
+		case syncTypeFormat:
+			format, err := compileKeyFormat(v.rawKey)
+			if err != nil {
+				logger.Logrus().Warningln(tag, "bad key format", bucketName, v.rawKey, err)
+				continue
+			}
+			logger.Logrus().Traceln(tag, "format evicted", bucketName, v.rawKey, bucket.evictMatch(format))
+		default:
+			bytes, err := bucket.getBytes(v.rawKey)
+			if err == nil && dataSum(bytes) != v.sum {
+				logger.Logrus().Traceln(tag, "changed", bucketName, v.rawKey)
+				_ = bucket.evict(v.rawKey)
+			}
+		}
+	}
+}
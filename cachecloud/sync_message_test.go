@@ -0,0 +1,94 @@
+package cachecloud
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyncMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []syncEntry
+	}{
+		{"changed", []syncEntry{
+			newChangedSyncEntry("user:1", []byte("a"), 0),
+			newChangedSyncEntry("user:<@.>2", []byte("b"), 30*time.Second),
+		}},
+		{"deleted", []syncEntry{newDeletedSyncEntry("user:1"), newDeletedSyncEntry("")}},
+		{"format", []syncEntry{newFormatSyncEntry("user:%d")}},
+		{"flush", []syncEntry{newFlushSyncEntry()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := encodeSyncMessage("bucket", tt.entries...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, bucket, entries, ok := decodeSyncMessage(payload)
+			if !ok || node != getNodeId() || bucket != "bucket" {
+				t.Fatalf("decode = %q %q %v, payload %s", node, bucket, ok, payload)
+			}
+			if !reflect.DeepEqual(entries, tt.entries) {
+				t.Errorf("entries = %+v, want %+v", entries, tt.entries)
+			}
+		})
+	}
+}
+
+func TestDecodeSyncMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []syncEntry
+		ok      bool
+	}{
+		{"legacy changed and deleted", "n<@.>b<@.>k1<@.>sum<@.>k2<@.>", []syncEntry{
+			{typ: syncTypeChanged, rawKey: "k1", sum: "sum"}, newDeletedSyncEntry("k2"),
+		}, true},
+		{"legacy flush", "n<@.>b<@.><@.>*", []syncEntry{newFlushSyncEntry()}, true},
+		{"legacy too short", "n<@.>b", nil, false},
+		{"legacy odd fields", "n<@.>b<@.>k1", nil, false},
+		{"v2 flush", `{"v":2,"t":"flush","n":"n","b":"b","ts":1}`, []syncEntry{newFlushSyncEntry()}, true},
+		{"v2 ttl", `{"v":2,"t":"changed","n":"n","b":"b","ts":1,"e":[{"k":"k","s":"s","ttl":1500}]}`, []syncEntry{
+			{typ: syncTypeChanged, rawKey: "k", sum: "s", ttl: 1500 * time.Millisecond},
+		}, true},
+		{"v2 unknown version", `{"v":3,"t":"flush","n":"n","b":"b","ts":1}`, nil, false},
+		{"v2 unknown type", `{"v":2,"t":"moved","n":"n","b":"b","ts":1,"e":[{"k":"k"}]}`, nil, false},
+		{"v2 changed without sum", `{"v":2,"t":"changed","n":"n","b":"b","ts":1,"e":[{"k":"k"}]}`, nil, false},
+		{"v2 no entries", `{"v":2,"t":"deleted","n":"n","b":"b","ts":1}`, nil, false},
+		{"v2 missing bucket", `{"v":2,"t":"flush","n":"n","ts":1}`, nil, false},
+		{"bad json", `{"v":2,`, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, entries, ok := decodeSyncMessage(tt.payload)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(entries, tt.want) {
+				t.Errorf("entries = %+v, want %+v", entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacySyncMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []syncEntry
+		want    []syncEntry
+	}{
+		{"changed", []syncEntry{newChangedSyncEntry("k", []byte("a"), 0)}, []syncEntry{newChangedSyncEntry("k", []byte("a"), 0)}},
+		{"delimiter in key becomes flush", []syncEntry{newDeletedSyncEntry("a<@.>b")}, []syncEntry{newFlushSyncEntry()}},
+		{"format becomes flush", []syncEntry{newFormatSyncEntry("user:%d")}, []syncEntry{newFlushSyncEntry()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, entries, ok := decodeSyncMessage(encodeLegacySyncMessage("b", tt.entries...))
+			if !ok || !reflect.DeepEqual(entries, tt.want) {
+				t.Errorf("entries = %+v %v, want %+v", entries, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/golang-acexy/starter-redis/redisstarter"
+	"github.com/redis/go-redis/v9"
+)
+
+// 基于redis stream的同步传输：同步消息持久化在stream中，每个实例记录已消费的最后一条消息ID
+// 断线重连后从该ID继续消费期间错过的消息，若期间的消息已被stream裁剪，则通过 SyncGapNotifier 通知丢失同步消息
+
+const (
+	defaultStreamMaxLen        = 10000
+	defaultStreamBlockTimeout  = 5 * time.Second
+	defaultStreamRetryInterval = time.Second
+	defaultStreamBatchSize     = 100
+
+	streamPayloadField = "p"
+	streamInitialId    = "0-0"
+)
+
+// RedisStreamOption redis stream同步传输设置 零值时使用默认值
+type RedisStreamOption struct {
+	MaxLen        int64         // stream保留的最大消息数(近似裁剪) 默认10000
+	BlockTimeout  time.Duration // 单次阻塞读取的最长等待时间 默认5秒
+	RetryInterval time.Duration // 读取失败后的重试间隔 默认1秒
+	BatchSize     int64         // 单次读取的最大消息数 默认100
+}
+
+func (o RedisStreamOption) maxLen() int64 {
+	if o.MaxLen > 0 {
+		return o.MaxLen
+	}
+	return defaultStreamMaxLen
+}
+
+func (o RedisStreamOption) blockTimeout() time.Duration {
+	if o.BlockTimeout > 0 {
+		return o.BlockTimeout
+	}
+	return defaultStreamBlockTimeout
+}
+
+func (o RedisStreamOption) retryInterval() time.Duration {
+	if o.RetryInterval > 0 {
+		return o.RetryInterval
+	}
+	return defaultStreamRetryInterval
+}
+
+func (o RedisStreamOption) batchSize() int64 {
+	if o.BatchSize > 0 {
+		return o.BatchSize
+	}
+	return defaultStreamBatchSize
+}
+
+// RedisStreamTransport 基于redis stream的同步传输
+type RedisStreamTransport struct {
+	option RedisStreamOption
+	syncGapHandlers
+}
+
+// NewRedisStreamTransport 创建基于redis stream的同步传输
+func NewRedisStreamTransport(option RedisStreamOption) *RedisStreamTransport {
+	return &RedisStreamTransport{option: option}
+}
+
+func (r *RedisStreamTransport) Publish(ctx context.Context, topic string, payload string) error {
+	return redisstarter.RawRedisClient().XAdd(ctx, &redis.XAddArgs{
+		Stream: topic,
+		MaxLen: r.option.maxLen(),
+		Approx: true,
+		Values: []string{streamPayloadField, payload},
+	}).Err()
+}
+
+func (r *RedisStreamTransport) Subscribe(ctx context.Context, topic string, handler func(payload string)) error {
+	go r.consume(ctx, topic, handler)
+	return nil
+}
+
+// consume 持续消费stream中的同步消息 直至ctx取消
+func (r *RedisStreamTransport) consume(ctx context.Context, topic string, handler func(payload string)) {
+	client := redisstarter.RawRedisClient()
+	lastId := ""
+	recovering := false
+	for ctx.Err() == nil {
+		if lastId == "" {
+			// 首次订阅仅消费此后发布的消息
+			id, err := latestStreamId(ctx, client, topic)
+			if err != nil {
+				logger.Logrus().Warningln("stream sync init failed", topic, err)
+				r.sleep(ctx)
+				continue
+			}
+			lastId = id
+		}
+		if recovering {
+			gap, err := streamGap(ctx, client, topic, lastId)
+			if err != nil {
+				logger.Logrus().Warningln("stream sync gap check failed", topic, err)
+				r.sleep(ctx)
+				continue
+			}
+			recovering = false
+			if gap {
+				logger.Logrus().Warningln("stream sync messages lost", topic, lastId)
+				lastId = streamInitialId
+				if id, err := latestStreamId(ctx, client, topic); err == nil {
+					lastId = id
+				}
+				r.notifyGap(topic)
+			}
+		}
+		streams, err := client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{topic, lastId},
+			Count:   r.option.batchSize(),
+			Block:   r.option.blockTimeout(),
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if ctx.Err() == nil {
+				logger.Logrus().Warningln("stream sync read failed", topic, err)
+				recovering = true
+				r.sleep(ctx)
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				lastId = message.ID
+				if payload, ok := message.Values[streamPayloadField].(string); ok {
+					handler(payload)
+				}
+			}
+			// 读取到完整批次说明消费落后 下次读取前检查是否已被裁剪
+			if int64(len(stream.Messages)) >= r.option.batchSize() {
+				recovering = true
+			}
+		}
+	}
+}
+
+func (r *RedisStreamTransport) sleep(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(r.option.retryInterval()):
+	}
+}
+
+// latestStreamId 获取stream中最新的消息ID stream为空时返回初始ID
+func latestStreamId(ctx context.Context, client redis.UniversalClient, topic string) (string, error) {
+	messages, err := client.XRevRangeN(ctx, topic, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return streamInitialId, nil
+	}
+	return messages[0].ID, nil
+}
+
+// streamGap 检查lastId之后的消息是否已被裁剪
+func streamGap(ctx context.Context, client redis.UniversalClient, topic, lastId string) (bool, error) {
+	exists, err := client.Exists(ctx, topic).Result()
+	if err != nil {
+		return false, err
+	}
+	if exists == 0 {
+		// stream已不存在 此前消费过消息时视为丢失
+		return lastId != streamInitialId, nil
+	}
+	info, err := client.XInfoStream(ctx, topic).Result()
+	if err != nil {
+		return false, err
+	}
+	return streamInfoGap(info, lastId), nil
+}
+
+// streamInfoGap 根据stream信息判断lastId之后的消息是否已被裁剪
+// redis 7.0 起可获取被删除的最大消息ID 此前的版本仅能比较第一条消息的ID
+// 第一条消息紧随lastId(同一毫秒内序号加一)时确定未丢失，其余晚于lastId的情况无法确定，视为丢失
+func streamInfoGap(info *redis.XInfoStream, lastId string) bool {
+	if info.MaxDeletedEntryID != "" && info.MaxDeletedEntryID != streamInitialId {
+		return compareStreamId(info.MaxDeletedEntryID, lastId) > 0
+	}
+	if info.FirstEntry.ID == "" || lastId == streamInitialId {
+		return false
+	}
+	return compareStreamId(info.FirstEntry.ID, lastId) > 0 && info.FirstEntry.ID != nextStreamId(lastId)
+}
+
+// nextStreamId 同一毫秒内紧随其后的消息ID
+func nextStreamId(id string) string {
+	ms, seq := parseStreamId(id)
+	return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq+1, 10)
+}
+
+// compareStreamId 比较两个stream消息ID的先后
+func compareStreamId(a, b string) int {
+	aMs, aSeq := parseStreamId(a)
+	bMs, bSeq := parseStreamId(b)
+	switch {
+	case aMs != bMs:
+		if aMs > bMs {
+			return 1
+		}
+		return -1
+	case aSeq != bSeq:
+		if aSeq > bSeq {
+			return 1
+		}
+		return -1
+	default:
+		return 0
+	}
+}
+
+func parseStreamId(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msValue, _ := strconv.ParseUint(ms, 10, 64)
+	seqValue, _ := strconv.ParseUint(seq, 10, 64)
+	return msValue, seqValue
+}
@@ -0,0 +1,52 @@
+package cachecloud
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCompareStreamId(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"2-0", "1-9", 1},
+		{"1-9", "2-0", -1},
+		{"1-10", "1-9", 1},
+		{"0-0", "1-0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareStreamId(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareStreamId(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestStreamInfoGap(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxDeleted string
+		first      string
+		lastId     string
+		want       bool
+	}{
+		{"max deleted before last", "5-0", "6-0", "5-0", false},
+		{"max deleted after last", "7-0", "8-0", "5-0", true},
+		{"max deleted unset", "0-0", "3-0", "5-0", false},
+		{"first before last", "", "3-0", "5-0", false},
+		{"first right after last", "", "5-1", "5-0", false},
+		{"first later than last", "", "9-0", "5-0", true},
+		{"empty stream", "", "", "5-0", false},
+		{"never consumed", "", "9-0", streamInitialId, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &redis.XInfoStream{MaxDeletedEntryID: tt.maxDeleted, FirstEntry: redis.XMessage{ID: tt.first}}
+			if got := streamInfoGap(info, tt.lastId); got != tt.want {
+				t.Errorf("streamInfoGap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
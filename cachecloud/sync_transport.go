@@ -0,0 +1,145 @@
+package cachecloud
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/golang-acexy/starter-redis/redisstarter"
+	"github.com/redis/go-redis/v9"
+)
+
+// 缓存同步传输：分布式内存缓存与二级缓存通过 SyncTransport 在实例间发布与订阅缓存变化事件
+// 默认使用redis发布订阅，可通过 Option.SyncTransport 替换
+
+// pubSubRetryInterval redis发布订阅连接关闭后重新订阅的间隔
+const pubSubRetryInterval = 5 * time.Second
+
+// syncTransport 当前使用的同步传输
+var syncTransport SyncTransport = newRedisSyncTransport()
+
+// SyncTransport 缓存同步消息的传输方式
+type SyncTransport interface {
+	// Publish 向指定主题发布同步消息
+	Publish(ctx context.Context, topic string, payload string) error
+	// Subscribe 订阅指定主题 每条同步消息调用一次handler 实现需自行处理断线重连
+	Subscribe(ctx context.Context, topic string, handler func(payload string)) error
+}
+
+// SyncGapNotifier 能够感知同步消息丢失的传输
+type SyncGapNotifier interface {
+	// NotifyGap 订阅的主题可能丢失了同步消息时调用fn
+	NotifyGap(topic string, fn func())
+}
+
+// syncGapHandlers 各主题同步消息丢失时的回调
+type syncGapHandlers struct {
+	mutex    sync.Mutex
+	handlers map[string][]func()
+}
+
+func (s *syncGapHandlers) NotifyGap(topic string, fn func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string][]func())
+	}
+	s.handlers[topic] = append(s.handlers[topic], fn)
+}
+
+func (s *syncGapHandlers) notifyGap(topic string) {
+	s.mutex.Lock()
+	handlers := s.handlers[topic]
+	s.mutex.Unlock()
+	for _, fn := range handlers {
+		fn()
+	}
+}
+
+// redisSyncTransport 基于redis发布订阅的同步传输 断线重连后重新订阅成功时视为可能丢失了同步消息
+type redisSyncTransport struct {
+	syncGapHandlers
+}
+
+func newRedisSyncTransport() *redisSyncTransport {
+	return &redisSyncTransport{}
+}
+
+func (r *redisSyncTransport) Publish(ctx context.Context, topic string, payload string) error {
+	return redisstarter.RawRedisClient().Publish(ctx, topic, payload).Err()
+}
+
+func (r *redisSyncTransport) Subscribe(ctx context.Context, topic string, handler func(payload string)) error {
+	go r.consume(ctx, topic, handler)
+	return nil
+}
+
+// consume 持续消费发布订阅的同步消息 直至ctx取消
+func (r *redisSyncTransport) consume(ctx context.Context, topic string, handler func(payload string)) {
+	subscribed := false
+	for ctx.Err() == nil {
+		pubSub := redisstarter.RawRedisClient().Subscribe(ctx, topic)
+		stop := context.AfterFunc(ctx, func() { _ = pubSub.Close() })
+		// 连接断开后go-redis会自动重连并重新订阅 每次订阅成功均会收到订阅确认
+		for v := range pubSub.ChannelWithSubscriptions() {
+			switch message := v.(type) {
+			case *redis.Subscription:
+				if message.Kind != "subscribe" {
+					continue
+				}
+				if subscribed {
+					logger.Logrus().Warningln("pub/sub sync resubscribed", topic)
+					r.notifyGap(topic)
+				}
+				subscribed = true
+			case *redis.Message:
+				handler(message.Payload)
+			}
+		}
+		stop()
+		_ = pubSub.Close()
+		select {
+		case <-ctx.Done():
+		case <-time.After(pubSubRetryInterval):
+		}
+	}
+}
+
+// LoopbackTransport 进程内的同步传输 发布的消息将同步投递给同一主题的所有订阅者(包括发布者自身)
+// 可在不依赖redis的情况下使用分布式内存缓存，或在同一进程中通过多个订阅者模拟多个实例
+type LoopbackTransport struct {
+	mutex    sync.RWMutex
+	handlers map[string][]func(payload string)
+	syncGapHandlers
+}
+
+// NewLoopbackTransport 创建进程内的同步传输
+func NewLoopbackTransport() *LoopbackTransport {
+	return &LoopbackTransport{handlers: make(map[string][]func(payload string))}
+}
+
+func (l *LoopbackTransport) Publish(ctx context.Context, topic string, payload string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mutex.RLock()
+	handlers := l.handlers[topic]
+	l.mutex.RUnlock()
+	for _, handler := range handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+func (l *LoopbackTransport) Subscribe(_ context.Context, topic string, handler func(payload string)) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.handlers[topic] = append(l.handlers[topic], handler)
+	return nil
+}
+
+// SimulateGap 模拟指定主题丢失了同步消息 订阅者将按存储桶配置的策略处理本地缓存
+func (l *LoopbackTransport) SimulateGap(topic string) {
+	l.notifyGap(topic)
+}
@@ -0,0 +1,174 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acexy/golang-toolkit/logger"
+	"github.com/golang-acexy/starter-redis/redisstarter"
+	"github.com/redis/go-redis/v9"
+)
+
+// 标签失效：写入缓存时可附加标签，通过 EvictByTag 清除所有附加了该标签的缓存(可跨存储桶)
+// 标签与缓存的关系以有序集合存储在redis中，成员分数为缓存的过期时间(毫秒，0表示不过期)，因此任意类型的存储桶使用标签均依赖redis
+// 清除时逐个存储桶调用 MEvict，分布式内存缓存和二级缓存将通过同步消息清除所有实例的本地缓存
+// Evict 等操作不会移除标签成员，已过期的成员在附加标签或 EvictByTag 时清理，未设置过期时间的成员保留至 EvictByTag
+
+const tagPopCount = 500 // 每次从标签中取出的缓存数量
+
+// tagAddScript 添加标签成员并清理已过期的成员 ARGV: 成员 成员过期时间 当前时间
+// 存在不过期的成员时标签不过期 否则标签与最晚过期的成员同时过期
+var tagAddScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 1, '(' .. ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+if redis.call('ZCOUNT', KEYS[1], 0, 0) > 0 then
+	redis.call('PERSIST', KEYS[1])
+else
+	local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+	redis.call('PEXPIREAT', KEYS[1], last[2])
+end
+return 1
+`)
+
+// tagKey 标签在redis中的key
+func tagKey(tag string) string {
+	prefix := "cache-tag:"
+	if serviceNamePrefix != "" {
+		prefix = serviceNamePrefix + ":" + prefix
+	}
+	return prefix + tag
+}
+
+// encodeTagMember 标签成员 存储桶名长度:存储桶名缓存key
+func encodeTagMember(bucketName BucketName, rawKey string) string {
+	return strconv.Itoa(len(bucketName)) + ":" + string(bucketName) + rawKey
+}
+
+func decodeTagMember(member string) (BucketName, string, bool) {
+	length, rest, ok := strings.Cut(member, ":")
+	if !ok {
+		return "", "", false
+	}
+	n, err := strconv.Atoi(length)
+	if err != nil || n <= 0 || n > len(rest) {
+		return "", "", false
+	}
+	return BucketName(rest[:n]), rest[n:], true
+}
+
+// defaultBucketExpire 存储桶未指定过期时间时写入缓存使用的过期时间
+func defaultBucketExpire(bucket CacheBucket) time.Duration {
+	switch b := bucket.(type) {
+	case *memeCacheBucket:
+		return b.bucket.defaultExpire()
+	case *distMemeCacheBucket:
+		return b.bucket.defaultExpire()
+	case *redisCacheBucket:
+		return b.defaultExpire()
+	case *secondLevelCacheBucket:
+		return b.redisBucket.defaultExpire()
+	}
+	return 0
+}
+
+// addTags 为缓存附加标签 expire 为该条缓存的过期时间
+func addTags(ctx context.Context, bucket CacheBucket, bucketName BucketName, rawKey string, expire time.Duration, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	if expire <= 0 {
+		expire = defaultBucketExpire(bucket)
+	}
+	member := encodeTagMember(bucketName, rawKey)
+	now := time.Now()
+	var expireAt int64
+	if expire > 0 {
+		expireAt = now.Add(expire).UnixMilli()
+	}
+	client := redisstarter.RawRedisClient()
+	for _, tag := range tags {
+		if err := tagAddScript.Run(ctx, client, []string{tagKey(tag)}, member, expireAt, now.UnixMilli()).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutCacheValueWithTags 通过指定的存储桶和缓存key，设置缓存值并附加标签 expire 小于等于零时使用存储桶配置的过期时间
+func PutCacheValueWithTags(ctx context.Context, bucketName BucketName, cacheKey CacheKey, data any, expire time.Duration, tags []string, keyAppend ...interface{}) error {
+	bucket := getBucket(bucketName)
+	if bucket == nil {
+		return errors.New("bucket not found")
+	}
+	// 先附加标签 避免写入的缓存无法通过标签清除
+	if err := addTags(ctx, bucket, bucketName, cacheKey.RawKeyString(keyAppend...), expire, tags); err != nil {
+		return err
+	}
+	return bucket.PutWithExpire(ctx, cacheKey, data, expire, keyAppend...)
+}
+
+// EvictByTag 清除所有附加了指定标签的缓存
+func EvictByTag(ctx context.Context, tags ...string) error {
+	client := redisstarter.RawRedisClient()
+	var lastErr error
+	for _, tag := range tags {
+		key := tagKey(tag)
+		for {
+			// 逐批取出标签成员 清除期间新附加的成员保留在标签中
+			members, err := client.ZPopMin(ctx, key, tagPopCount).Result()
+			if err != nil {
+				if !errors.Is(err, redis.Nil) {
+					lastErr = err
+				}
+				break
+			}
+			if len(members) == 0 {
+				break
+			}
+			if err = evictTagMembers(ctx, members); err != nil {
+				// 清除失败时放回标签 以便再次清除
+				_ = client.ZAdd(context.WithoutCancel(ctx), key, members...).Err()
+				lastErr = err
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+	}
+	return lastErr
+}
+
+// evictTagMembers 按存储桶批量清除标签成员对应的缓存 已过期的成员直接丢弃
+func evictTagMembers(ctx context.Context, members []redis.Z) error {
+	keys := make(map[BucketName][]BatchKey)
+	now := float64(time.Now().UnixMilli())
+	for _, z := range members {
+		if z.Score > 0 && z.Score < now {
+			continue
+		}
+		member, _ := z.Member.(string)
+		bucketName, rawKey, ok := decodeTagMember(member)
+		if !ok {
+			logger.Logrus().Warningln("bad cache tag member", member)
+			continue
+		}
+		// 原始缓存key作为格式且不附加参数 RawKeyString 将原样返回
+		keys[bucketName] = append(keys[bucketName], BatchKey{Key: CacheKey{KeyFormat: rawKey}})
+	}
+	var lastErr error
+	for bucketName, batch := range keys {
+		bucket := getBucket(bucketName)
+		if bucket == nil {
+			continue
+		}
+		if err := bucket.MEvict(ctx, batch); err != nil {
+			logger.Logrus().Warningln("evict by tag failed", bucketName, len(batch), err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,93 @@
+package cachecloud
+
+import (
+	"context"
+	"errors"
+)
+
+// 缓存操作追踪：通过 Option.Tracer 接入任意追踪系统，cachecloud 不依赖具体的追踪库
+
+// 追踪的缓存操作
+const (
+	TraceOpGet       = "get"
+	TraceOpPut       = "put"
+	TraceOpEvict     = "evict"
+	TraceOpCacheable = "cacheable"
+	TraceOpSync      = "sync" // 处理其它实例发布的缓存变化事件
+)
+
+// 命中的缓存层
+const (
+	TraceTierLocal    = "local"
+	TraceTierRedis    = "redis"
+	TraceTierSupplier = "supplier" // Cacheable 通过supplier获取数据
+)
+
+var cacheTracer Tracer
+var traceHashKey bool
+
+// Tracer 缓存操作追踪器
+type Tracer interface {
+	// Start 缓存操作开始 返回的ctx将用于该操作内部的后续调用
+	Start(ctx context.Context, span SpanStart) (context.Context, Span)
+}
+
+// Span 一次缓存操作
+type Span interface {
+	// End 缓存操作结束
+	End(end SpanEnd)
+}
+
+// SpanStart 缓存操作开始时的信息
+type SpanStart struct {
+	Operation  string
+	BucketName BucketName
+	BucketType BucketType
+	Key        string // 原始缓存key 启用 Option.TraceHashKey 时为摘要 批量的同步事件为空
+}
+
+// SpanEnd 缓存操作结束时的信息
+type SpanEnd struct {
+	Tier string // 命中的缓存层 未命中或非获取操作时为空 Cacheable 命中缓存时为空，由其内部的获取操作记录
+	Hit  bool   // 获取操作是否命中缓存(包含命中"不存在"标记)
+	Err  error
+}
+
+// startSpan 开始追踪缓存操作 未设置追踪器时返回nil
+func startSpan(ctx context.Context, operation string, stats *bucketStats, rawKey string) (context.Context, Span) {
+	if cacheTracer == nil || stats == nil {
+		return ctx, nil
+	}
+	if traceHashKey && rawKey != "" {
+		rawKey = dataSum([]byte(rawKey))
+	}
+	return cacheTracer.Start(ctx, SpanStart{
+		Operation:  operation,
+		BucketName: stats.key.bucketName,
+		BucketType: stats.key.bucketType,
+		Key:        rawKey,
+	})
+}
+
+// endSpan 结束追踪缓存操作
+func endSpan(span Span, end SpanEnd) {
+	if span != nil {
+		span.End(end)
+	}
+}
+
+// endGetSpan 结束追踪获取操作 命中时记录命中的缓存层
+func endGetSpan(span Span, tier statsTier, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil && !errors.Is(err, ErrCachedNotFound) {
+		span.End(SpanEnd{Err: err})
+		return
+	}
+	end := SpanEnd{Tier: TraceTierLocal, Hit: true, Err: err}
+	if tier == tierRedis {
+		end.Tier = TraceTierRedis
+	}
+	span.End(end)
+}
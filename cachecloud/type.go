@@ -1,6 +1,7 @@
 package cachecloud
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -13,19 +14,37 @@ const (
 	BucketTypeLevel2             = "level-2"
 
 	topicDelimiter = "<@.>"
+
+	scanCount = 500 // SCAN 每次遍历的数量
 )
 
 var ErrCacheMiss = errors.New("cache miss")
 
+// ErrCachedNotFound 命中否定缓存 数据源中确认不存在该数据
+var ErrCachedNotFound = errors.New("cached not found")
+
+// ErrNotFound Loader 返回该错误(或包装该错误)表示数据源中不存在该数据
+var ErrNotFound = errors.New("not found")
+
 type Option struct {
 	ServiceName string // 服务名称 可用于防止隔离不同服务使用相同redis出现的key冲突
 	// 是否允许自动开启二级缓存
 	// 启用时：如果mem缓存类型与redis缓存类型的存储桶出现相同名，那么该存储桶将自动启用二级缓存管理机制
 	// 如果检测到mem缓存存储桶被适配了二级缓存机制，原始定义的mem缓存类型的存储桶将自动放弃初始化
 	AutoEnable2LevelCache bool
+	// 缓存操作追踪 为空时不追踪
+	Tracer Tracer
+	// 追踪时是否以摘要代替原始缓存key 避免缓存key中的敏感信息进入追踪系统
+	TraceHashKey bool
+	// 分布式内存缓存与二级缓存的同步传输 为空时使用redis发布订阅
+	SyncTransport SyncTransport
+	// 是否发布旧版格式的同步消息 滚动升级期间仍有旧版本实例时开启，所有实例升级后关闭
+	LegacySyncMessage bool
+	// 同步消息可能丢失时的回调 每个受影响的存储桶调用一次
+	OnSyncGap func(event SyncGapEvent)
 }
 
-// BucketName 存储桶名称
+// BucketName 存储桶名称 redis缓存与二级缓存的存储桶名称不能包含":"，且不能为 l2、cache-lock、cache-tag
 type BucketName string
 
 // BucketType 存储桶类型
@@ -33,12 +52,128 @@ type BucketType string
 
 type Supplier[T any] func() (T, bool)
 
+// Loader 从数据源加载数据 数据不存在时返回 ErrNotFound，其它错误将原样返回给调用方且不会被缓存
+type Loader[T any] func(ctx context.Context) (T, error)
+
+// CacheableOption Cacheable 扩展选项
+type CacheableOption struct {
+	// Expire 重建缓存时该条缓存的过期时间 零值时使用存储桶配置的过期时间
+	Expire time.Duration
+	// ExpireAt 重建缓存时该条缓存的绝对过期时间 非零值时优先于 Expire
+	ExpireAt time.Time
+	// DistLock 非空时启用分布式重建锁 多个实例中仅有一个实例调用supplier 仅对redis缓存和二级缓存生效
+	DistLock *DistLockOption
+	// Tags 重建缓存时为该条缓存附加的标签 可通过 EvictByTag 清除
+	Tags []string
+}
+
+// expire 计算本次写入缓存的过期时间
+func (c CacheableOption) expire() (time.Duration, error) {
+	if !c.ExpireAt.IsZero() {
+		return expireUntil(c.ExpireAt)
+	}
+	return c.Expire, nil
+}
+
 // CacheConfig 缓存key
 type CacheConfig struct {
 	bucketName  BucketName    // 存储桶名称
 	memExpire   time.Duration // 内存过期时间
 	redisExpire time.Duration // redis过期时间
 	typ         BucketType    // 存储桶类型
+	codec       Codec         // 缓存值编解码器
+
+	negativeExpire time.Duration // 否定缓存过期时间 零值时不启用
+	softExpire     time.Duration // 数据过时时间 超过该时间的数据仍可返回但需后台刷新
+	hardExpire     time.Duration // 数据最长存活时间
+
+	gapAction SyncGapAction // 同步消息可能丢失后本地缓存的处理策略
+	gapTTL    time.Duration // SyncGapShorten 时本地缓存剩余过期时间的上限
+
+	batchWindow time.Duration // 同步消息合并发布的时间窗口 零值时不启用
+	batchSize   int           // 同步消息合并发布的批次大小
+
+	slideInterval time.Duration // 滑动过期的最小延长间隔 零值时不启用
+
+	redisKeyPrefix string // 二级缓存redis层的key前缀 为空时使用二级缓存默认的前缀
+}
+
+// WithCodec 指定存储桶使用的缓存值编解码器 默认使用 GobCodec
+func (c CacheConfig) WithCodec(codec Codec) CacheConfig {
+	c.codec = codec
+	return c
+}
+
+// WithNegativeCache 启用否定缓存 Cacheable 的supplier未能获取数据时写入"不存在"标记
+// 标记在expire内有效，期间获取该缓存将返回 ErrCachedNotFound
+func (c CacheConfig) WithNegativeCache(expire time.Duration) CacheConfig {
+	c.negativeExpire = expire
+	return c
+}
+
+// WithStaleWhileRevalidate 启用过时数据后台刷新 softExpire 需小于 hardExpire
+// 写入的缓存默认存活 hardExpire(内存缓存仍受限于内存过期时间)，超过 softExpire 后 Cacheable 仍直接返回该数据并在后台异步刷新，
+// 超过 hardExpire 后缓存失效，Cacheable 将阻塞等待supplier
+// redis缓存依据剩余过期时间判断数据是否过时，因此单独指定过期时间写入的缓存不适用
+func (c CacheConfig) WithStaleWhileRevalidate(softExpire, hardExpire time.Duration) CacheConfig {
+	c.softExpire = softExpire
+	c.hardExpire = hardExpire
+	return c
+}
+
+// WithSyncGapShorten 同步消息可能丢失后将本地缓存的剩余过期时间缩短至不超过maxTTL 默认清空本地缓存
+// 仅对分布式内存缓存和二级缓存生效
+func (c CacheConfig) WithSyncGapShorten(maxTTL time.Duration) CacheConfig {
+	c.gapAction = SyncGapShorten
+	c.gapTTL = maxTTL
+	return c
+}
+
+// WithSyncGapRevalidate 同步消息可能丢失后将本地缓存标记为过时 默认清空本地缓存
+// 标记后 Cacheable 仍返回该数据并在后台调用supplier刷新，Get 不受影响 仅对分布式内存缓存和二级缓存生效
+func (c CacheConfig) WithSyncGapRevalidate() CacheConfig {
+	c.gapAction = SyncGapRevalidate
+	return c
+}
+
+// WithSyncBatch 启用同步消息合并发布 缓存变化事件暂存window后合并发布，暂存数量达到maxSize时立即发布
+// maxSize 小于等于零时默认为1000 其它实例最多延迟window感知缓存变化 仅对分布式内存缓存和二级缓存生效
+// 应用退出前需调用 Shutdown 发布暂存的缓存变化事件
+func (c CacheConfig) WithSyncBatch(window time.Duration, maxSize int) CacheConfig {
+	c.batchWindow = window
+	c.batchSize = maxSize
+	return c
+}
+
+// WithSlidingExpire 启用滑动过期 成功获取缓存后按其写入时的过期时间重新计算过期时间 单独指定的过期时间同样生效
+// 同一缓存在interval内最多延长一次，redis缓存的延长按interval批量发送 与 WithStaleWhileRevalidate 同时启用时不生效
+func (c CacheConfig) WithSlidingExpire(interval time.Duration) CacheConfig {
+	c.slideInterval = interval
+	return c
+}
+
+// slidingInterval 获取滑动过期的最小延长间隔 未启用或启用了过时数据后台刷新时返回零值
+func (c CacheConfig) slidingInterval() time.Duration {
+	if softExpire, _ := c.staleExpire(); softExpire > 0 {
+		return 0
+	}
+	return max(c.slideInterval, 0)
+}
+
+// staleExpire 获取过时数据后台刷新的时间设置 未启用或设置无效时均返回零值
+func (c CacheConfig) staleExpire() (softExpire, hardExpire time.Duration) {
+	if c.softExpire <= 0 || c.hardExpire <= c.softExpire {
+		return 0, 0
+	}
+	return c.softExpire, c.hardExpire
+}
+
+// getCodec 获取存储桶使用的缓存值编解码器
+func (c CacheConfig) getCodec() Codec {
+	if c.codec == nil {
+		return GobCodec
+	}
+	return c.codec
 }
 
 type CacheKey struct {
@@ -54,9 +189,20 @@ func (c CacheKey) RawKeyString(keyAppend ...interface{}) string {
 	return c.KeyFormat
 }
 
+// BatchKey 批量操作中的单个缓存key
+type BatchKey struct {
+	Key       CacheKey
+	KeyAppend []interface{}
+}
+
+// RawKeyString 返回原始的key字符串
+func (b BatchKey) RawKeyString() string {
+	return b.Key.RawKeyString(b.KeyAppend...)
+}
+
 type CacheBucket interface {
 	// Get 获取指定key对应的值
-	// result 值类型指针 缓存未命中时返回标准错误 ErrCacheMiss
+	// result 值类型指针 缓存未命中时返回标准错误 ErrCacheMiss 命中否定缓存时返回 ErrCachedNotFound
 	Get(key CacheKey, result any, keyAppend ...interface{}) error
 
 	// Put 设置key对应值
@@ -64,4 +210,29 @@ type CacheBucket interface {
 
 	// Evict 清除缓存
 	Evict(key CacheKey, keyAppend ...interface{}) error
+
+	// GetWithContext 获取指定key对应的值 遵循ctx的超时与取消
+	GetWithContext(ctx context.Context, key CacheKey, result any, keyAppend ...interface{}) error
+
+	// PutWithContext 设置key对应值 遵循ctx的超时与取消
+	PutWithContext(ctx context.Context, key CacheKey, data any, keyAppend ...interface{}) error
+
+	// PutWithExpire 设置key对应值并单独指定该条缓存的过期时间 expire 小于等于零时使用存储桶配置的过期时间
+	// 内存缓存受限于存储桶配置的内存过期时间，实际生效的过期时间不会超过该值；二级缓存中内存缓存不会晚于redis缓存过期
+	PutWithExpire(ctx context.Context, key CacheKey, data any, expire time.Duration, keyAppend ...interface{}) error
+
+	// EvictWithContext 清除缓存 遵循ctx的超时与取消
+	EvictWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) error
+
+	// MGet 批量获取 results 与 keys 一一对应且为值类型指针，返回每个key是否命中 否定缓存视为未命中
+	MGet(ctx context.Context, keys []BatchKey, results []any) ([]bool, error)
+
+	// MPut 批量设置 data 与 keys 一一对应
+	MPut(ctx context.Context, keys []BatchKey, data []any) error
+
+	// MEvict 批量清除缓存
+	MEvict(ctx context.Context, keys []BatchKey) error
+
+	// Clear 清空存储桶中所有缓存 分布式内存缓存和二级缓存将通知所有实例清空本地缓存
+	Clear(ctx context.Context) error
 }
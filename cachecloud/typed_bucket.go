@@ -0,0 +1,127 @@
+package cachecloud
+
+import (
+	"context"
+	"time"
+)
+
+// 类型安全的存储桶：TypedBucket[T] 在编译期约束缓存值类型，底层可以是任意类型的存储桶
+// 存储桶在每次操作时按名称查找，因此可以在 Init 之前创建
+
+// TypedBucket 缓存值类型为T的存储桶
+type TypedBucket[T any] struct {
+	bucketName BucketName
+}
+
+// NewTypedBucket 通过存储桶名称创建缓存值类型为T的存储桶 存储桶的选择与 GetBucket 一致
+func NewTypedBucket[T any](bucketName BucketName) TypedBucket[T] {
+	return TypedBucket[T]{bucketName: bucketName}
+}
+
+// Name 存储桶名称
+func (b TypedBucket[T]) Name() BucketName {
+	return b.bucketName
+}
+
+// Get 获取指定key对应的值 缓存未命中时返回 ErrCacheMiss 命中否定缓存时返回 ErrCachedNotFound
+func (b TypedBucket[T]) Get(key CacheKey, keyAppend ...interface{}) (T, error) {
+	return b.GetWithContext(context.Background(), key, keyAppend...)
+}
+
+// GetWithContext 获取指定key对应的值 遵循ctx的超时与取消
+func (b TypedBucket[T]) GetWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) (T, error) {
+	var result T
+	if err := GetCacheValueWithContext(ctx, b.bucketName, key, &result, keyAppend...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// Put 设置key对应值
+func (b TypedBucket[T]) Put(key CacheKey, data T, keyAppend ...interface{}) error {
+	return PutCacheValueWithContext(context.Background(), b.bucketName, key, data, keyAppend...)
+}
+
+// PutWithContext 设置key对应值 遵循ctx的超时与取消
+func (b TypedBucket[T]) PutWithContext(ctx context.Context, key CacheKey, data T, keyAppend ...interface{}) error {
+	return PutCacheValueWithContext(ctx, b.bucketName, key, data, keyAppend...)
+}
+
+// PutWithExpire 设置key对应值并单独指定该条缓存的过期时间
+func (b TypedBucket[T]) PutWithExpire(ctx context.Context, key CacheKey, data T, expire time.Duration, keyAppend ...interface{}) error {
+	return PutCacheValueWithExpire(ctx, b.bucketName, key, data, expire, keyAppend...)
+}
+
+// Evict 清除缓存
+func (b TypedBucket[T]) Evict(key CacheKey, keyAppend ...interface{}) error {
+	return EvictCacheWithContext(context.Background(), b.bucketName, key, keyAppend...)
+}
+
+// EvictWithContext 清除缓存 遵循ctx的超时与取消
+func (b TypedBucket[T]) EvictWithContext(ctx context.Context, key CacheKey, keyAppend ...interface{}) error {
+	return EvictCacheWithContext(ctx, b.bucketName, key, keyAppend...)
+}
+
+// GetOrLoad 获取缓存值，如果缓存值不存在，则调用loader获取值并设置缓存值 行为与 Cacheable 一致
+func (b TypedBucket[T]) GetOrLoad(key CacheKey, loader Supplier[T], keyAppend ...interface{}) (T, error) {
+	return b.GetOrLoadWithOption(context.Background(), key, loader, CacheableOption{}, keyAppend...)
+}
+
+// GetOrLoadWithOption 获取缓存值，如果缓存值不存在，则调用loader获取值并按照option设置缓存值 行为与 CacheableWithOption 一致
+func (b TypedBucket[T]) GetOrLoadWithOption(ctx context.Context, key CacheKey, loader Supplier[T], option CacheableOption, keyAppend ...interface{}) (T, error) {
+	var supplier Supplier[*T]
+	if loader != nil {
+		supplier = func() (*T, bool) {
+			value, ok := loader()
+			return &value, ok
+		}
+	}
+	var result T
+	if err := CacheableWithOption[T](ctx, b.bucketName, key, &result, supplier, option, keyAppend...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// MGet 批量获取 返回的值与每个key是否命中均与keys一一对应 未命中或解码失败的值为零值
+// 部分失败时同时返回已命中的值与错误
+func (b TypedBucket[T]) MGet(ctx context.Context, keys []BatchKey) ([]T, []bool, error) {
+	values := make([]T, len(keys))
+	results := make([]any, len(keys))
+	for i := range values {
+		results[i] = &values[i]
+	}
+	hits, err := MGetCacheValue(ctx, b.bucketName, keys, results)
+	if len(hits) != len(keys) {
+		hits = make([]bool, len(keys))
+	}
+	// 解码失败的值可能已被部分写入
+	for i, hit := range hits {
+		if !hit {
+			var zero T
+			values[i] = zero
+		}
+	}
+	return values, hits, err
+}
+
+// MPut 批量设置 data 与 keys 一一对应
+func (b TypedBucket[T]) MPut(ctx context.Context, keys []BatchKey, data []T) error {
+	values := make([]any, len(data))
+	for i, v := range data {
+		values[i] = v
+	}
+	return MPutCacheValue(ctx, b.bucketName, keys, values)
+}
+
+// MEvict 批量清除缓存
+func (b TypedBucket[T]) MEvict(ctx context.Context, keys []BatchKey) error {
+	return MEvictCache(ctx, b.bucketName, keys)
+}
+
+// Clear 清空存储桶中所有缓存
+func (b TypedBucket[T]) Clear(ctx context.Context) error {
+	return ClearBucket(ctx, b.bucketName)
+}
@@ -0,0 +1,45 @@
+package cachecloud
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// useTestMemBuckets 以指定配置注册内存缓存存储桶 测试结束后移除
+func useTestMemBuckets(t *testing.T, configs ...CacheConfig) {
+	t.Helper()
+	memCache = &memCacheManager{
+		locals:  newLocalCacheBuckets(configs...),
+		buckets: make(map[string]*memeCacheBucket),
+	}
+	useMemCache = true
+	t.Cleanup(func() {
+		memCache, useMemCache = nil, false
+	})
+}
+
+func TestTypedBucketMGetPartial(t *testing.T) {
+	type pair struct {
+		A int
+		B int
+	}
+	useTestMemBuckets(t, NewMemCacheConfig("typed", time.Hour).WithCodec(JSONCodec))
+	local := memCache.locals["typed"]
+	_ = local.putBytes("ok", []byte(`{"A":1,"B":2}`), 0)
+	// A 解码成功后 B 解码失败
+	_ = local.putBytes("bad", []byte(`{"A":3,"B":"x"}`), 0)
+
+	keys := []BatchKey{{Key: CacheKey{KeyFormat: "ok"}}, {Key: CacheKey{KeyFormat: "bad"}}, {Key: CacheKey{KeyFormat: "missing"}}}
+	values, hits, err := NewTypedBucket[pair]("typed").MGet(context.Background(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHits := []bool{true, false, false}
+	wantValues := []pair{{1, 2}, {}, {}}
+	for i := range keys {
+		if hits[i] != wantHits[i] || values[i] != wantValues[i] {
+			t.Errorf("key %d = %v %+v, want %v %+v", i, hits[i], values[i], wantHits[i], wantValues[i])
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package cachecloud
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/acexy/golang-toolkit/crypto/hashing"
 	"github.com/acexy/golang-toolkit/math/conversion"
@@ -18,3 +21,23 @@ func getNodeId() string {
 	})
 	return nodeId
 }
+
+// expireUntil 将绝对过期时间转换为剩余的过期时间
+func expireUntil(expireAt time.Time) (time.Duration, error) {
+	expire := time.Until(expireAt)
+	if expire <= 0 {
+		return 0, errors.New("expire time has passed")
+	}
+	return expire, nil
+}
+
+// checkBatchArgs 校验批量操作参数
+func checkBatchArgs(ctx context.Context, keys []BatchKey, values []any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(keys) != len(values) {
+		return errors.New("keys and values length mismatch")
+	}
+	return nil
+}
@@ -4,14 +4,14 @@ go 1.24.6
 
 require (
 	github.com/acexy/golang-toolkit v0.0.61
+	github.com/allegro/bigcache/v3 v3.1.0
+	github.com/bsm/redislock v0.9.4
 	github.com/golang-acexy/starter-parent v0.1.22
 	github.com/golang-acexy/starter-redis v0.1.16
 	github.com/redis/go-redis/v9 v9.17.2
 )
 
 require (
-	github.com/allegro/bigcache/v3 v3.1.0 // indirect
-	github.com/bsm/redislock v0.9.4 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/google/uuid v1.6.0 // indirect
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/acexy/golang-toolkit/util/json"
+	"github.com/golang-acexy/cloud-cache/cachecloud"
+)
+
+func TestMemBatch(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "user:%d"}
+	keys := []cachecloud.BatchKey{
+		cachecloud.NewBatchKey(cacheKeyTest, 1),
+		cachecloud.NewBatchKey(cacheKeyTest, 2),
+		cachecloud.NewBatchKey(cacheKeyTest, 3),
+	}
+	_ = cachecloud.MPutCacheValue(context.Background(), oneHourBucket, keys[:2], []any{
+		Model{Name: "acexy1", Sex: 1, Age: 18},
+		Model{Name: "acexy2", Sex: 0, Age: 19},
+	})
+
+	values := make([]Model, len(keys))
+	results := make([]any, len(keys))
+	for i := range values {
+		results[i] = &values[i]
+	}
+	hits, err := cachecloud.MGetCacheValue(context.Background(), oneHourBucket, keys, results)
+	fmt.Println(hits, err, json.ToString(values))
+	if err != nil || !slices.Equal(hits, []bool{true, true, false}) {
+		t.Fatalf("hits = %v, err = %v", hits, err)
+	}
+	if values[0].Name != "acexy1" || values[1].Name != "acexy2" {
+		t.Errorf("values = %s", json.ToString(values))
+	}
+
+	// 批量清除后获取
+	_ = cachecloud.MEvictCache(context.Background(), oneHourBucket, keys)
+	hits, err = cachecloud.MGetCacheValue(context.Background(), oneHourBucket, keys, results)
+	fmt.Println(hits, err)
+	if err != nil || !slices.Equal(hits, []bool{false, false, false}) {
+		t.Errorf("hits after evict = %v, err = %v", hits, err)
+	}
+}
@@ -1,7 +1,11 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -47,3 +51,144 @@ func TestCacheable(t *testing.T) {
 		done <- true
 	})
 }
+
+func TestCacheableCoalesced(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "hot"}
+	var supplierCalls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result int
+			_ = cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, func() (*int, bool) {
+				supplierCalls.Add(1)
+				time.Sleep(time.Millisecond * 200)
+				value := 1
+				return &value, true
+			})
+		}()
+	}
+	wg.Wait()
+	fmt.Println("supplier calls", supplierCalls.Load(), "coalesced", cachecloud.CoalescedCalls(oneHourBucket))
+	if calls := supplierCalls.Load(); calls != 1 {
+		t.Errorf("supplier calls = %d, want 1", calls)
+	}
+}
+
+func TestCacheableDistLock(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewRedisCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "hot"}
+	_ = cachecloud.EvictCache(oneHourBucket, cacheKeyTest)
+	// 多个实例同时运行该测试时仅有一个实例调用supplier
+	var result int
+	err := cachecloud.CacheableWithOption[int](context.Background(), oneHourBucket, cacheKeyTest, &result, func() (*int, bool) {
+		logger.Logrus().Debugln("获取新值")
+		time.Sleep(time.Second)
+		value := random.RandInt(10)
+		return &value, true
+	}, cachecloud.CacheableOption{
+		DistLock: &cachecloud.DistLockOption{
+			LockTTL:     time.Second * 5,
+			WaitTimeout: time.Second * 3,
+			Fallback:    cachecloud.DistLockFallbackError,
+		},
+	})
+	fmt.Println(result, err)
+}
+
+func TestCacheableNegative(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test"},
+		cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour).WithNegativeCache(time.Second*2),
+	)
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "user:%d"}
+	var supplierCalls atomic.Int32
+	supplier := func() (*int, bool) {
+		fmt.Println("查询数据源")
+		supplierCalls.Add(1)
+		return nil, false
+	}
+	var result int
+	if err := cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier, 404); !errors.Is(err, cachecloud.ErrCacheMiss) {
+		t.Errorf("first call err = %v, want ErrCacheMiss", err)
+	}
+	// 否定缓存有效期内不再调用supplier
+	if err := cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier, 404); !errors.Is(err, cachecloud.ErrCachedNotFound) {
+		t.Errorf("second call err = %v, want ErrCachedNotFound", err)
+	}
+	if calls := supplierCalls.Load(); calls != 1 {
+		t.Errorf("supplier calls = %d, want 1", calls)
+	}
+	time.Sleep(time.Second * 3)
+	fmt.Println("等待3秒后继续获取")
+	if err := cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier, 404); !errors.Is(err, cachecloud.ErrCacheMiss) {
+		t.Errorf("call after expire err = %v, want ErrCacheMiss", err)
+	}
+	if calls := supplierCalls.Load(); calls != 2 {
+		t.Errorf("supplier calls = %d, want 2", calls)
+	}
+}
+
+func TestCacheableWithLoader(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test"},
+		cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour).WithNegativeCache(time.Second*2),
+	)
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "user:%d"}
+	loader := func(ctx context.Context) (int, error) {
+		fmt.Println("查询数据源")
+		return 0, errors.New("database timeout")
+	}
+	var result int
+	// 数据源错误原样返回且不会被缓存
+	fmt.Println(cachecloud.CacheableWithLoader[int](context.Background(), oneHourBucket, cacheKeyTest, &result, loader, 500))
+	fmt.Println(cachecloud.CacheableWithLoader[int](context.Background(), oneHourBucket, cacheKeyTest, &result, loader, 500))
+
+	notFound := func(ctx context.Context) (int, error) {
+		fmt.Println("查询数据源")
+		return 0, fmt.Errorf("user 404: %w", cachecloud.ErrNotFound)
+	}
+	fmt.Println(cachecloud.CacheableWithLoader[int](context.Background(), oneHourBucket, cacheKeyTest, &result, notFound, 404))
+	// 否定缓存有效期内不再调用loader
+	fmt.Println(cachecloud.CacheableWithLoader[int](context.Background(), oneHourBucket, cacheKeyTest, &result, notFound, 404))
+
+	fmt.Println(cachecloud.CacheableWithLoader[int](context.Background(), oneHourBucket, cacheKeyTest, &result, func(ctx context.Context) (int, error) {
+		return 200, nil
+	}, 200), result)
+}
+
+func TestCacheableStaleWhileRevalidate(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test"},
+		cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour).WithStaleWhileRevalidate(time.Second, time.Second*3),
+	)
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "stale"}
+	var version atomic.Int32
+	supplier := func() (*int, bool) {
+		value := int(version.Add(1))
+		fmt.Println("调用supplier", value)
+		return &value, true
+	}
+	var result int
+	fmt.Println(cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier), result)
+	time.Sleep(time.Millisecond * 1500)
+	// 已过时 直接返回旧值并在后台刷新
+	fmt.Println(cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier), result)
+	time.Sleep(time.Millisecond * 100)
+	fmt.Println(cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier), result)
+	time.Sleep(time.Second * 4)
+	// 已超过hardExpire 阻塞等待supplier
+	fmt.Println(cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, supplier), result)
+}
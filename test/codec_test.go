@@ -0,0 +1,27 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/acexy/golang-toolkit/util/json"
+	"github.com/golang-acexy/cloud-cache/cachecloud"
+)
+
+func TestJSONCodec(t *testing.T) {
+	jsonBucket := cachecloud.BucketName("json")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test"},
+		cachecloud.NewMemCacheConfig(jsonBucket, time.Hour).WithCodec(cachecloud.JSONCodec),
+	)
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	_ = cachecloud.PutCacheValue(jsonBucket, cacheKeyTest, Model{
+		Name: "acexy",
+		Sex:  1,
+		Age:  18,
+	})
+	var value Model
+	fmt.Println(cachecloud.GetCacheValue(jsonBucket, cacheKeyTest, &value), json.ToString(value))
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -125,3 +126,163 @@ func TestDistMemDeleted(t *testing.T) {
 	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
 	_ = cachecloud.EvictCache(oneHourBucket, cacheKeyTest)
 }
+
+func TestDistMemLoopback(t *testing.T) {
+	transport := cachecloud.NewLoopbackTransport()
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", SyncTransport: transport},
+		cachecloud.NewDistMemCacheConfig(oneHourBucket, time.Hour),
+	)
+	// 模拟另一个实例 打印收到的同步消息
+	topic := "test:dis-mem-sync-topic"
+	_ = transport.Subscribe(context.Background(), topic, func(payload string) {
+		fmt.Println("peer received", payload)
+	})
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	_ = cachecloud.PutCacheValue(oneHourBucket, cacheKeyTest, Model{Name: "acexy"})
+	var value Model
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value), json.ToString(value))
+	// 模拟另一个实例删除该缓存
+	_ = transport.Publish(context.Background(), topic, "peer<@.>1h<@.>test<@.>")
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value))
+}
+
+func TestDistMemSyncMessage(t *testing.T) {
+	transport := cachecloud.NewLoopbackTransport()
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", SyncTransport: transport},
+		cachecloud.NewDistMemCacheConfig(oneHourBucket, time.Hour),
+	)
+	topic := "test:dis-mem-sync-topic"
+	_ = transport.Subscribe(context.Background(), topic, func(payload string) {
+		fmt.Println("peer received", payload)
+	})
+
+	// 缓存key中包含旧版分隔符
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test<@.>1h"}
+	_ = cachecloud.PutCacheValue(oneHourBucket, cacheKeyTest, Model{Name: "acexy"})
+	// 格式错误的同步消息将被忽略
+	for _, payload := range []string{"", "{", "{\"v\":3}", "peer<@.>1h<@.>", "<@.><@.><@.>", "{\"v\":2,\"t\":\"deleted\",\"n\":\"peer\",\"b\":\"1h\"}"} {
+		_ = transport.Publish(context.Background(), topic, payload)
+	}
+	var value Model
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value), json.ToString(value))
+	// 模拟另一个实例删除该缓存
+	_ = transport.Publish(context.Background(), topic, `{"v":2,"t":"deleted","n":"peer","b":"1h","e":[{"k":"test<@.>1h"}]}`)
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value))
+}
+
+func TestDistMemSyncGap(t *testing.T) {
+	transport := cachecloud.NewLoopbackTransport()
+	flushBucket := cachecloud.BucketName("flush")
+	shortenBucket := cachecloud.BucketName("shorten")
+	revalidateBucket := cachecloud.BucketName("revalidate")
+	foreverBucket := cachecloud.BucketName("forever")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", SyncTransport: transport, OnSyncGap: func(event cachecloud.SyncGapEvent) {
+			fmt.Println("sync gap", json.ToString(event))
+		}},
+		cachecloud.NewDistMemCacheConfig(flushBucket, time.Hour),
+		cachecloud.NewDistMemCacheConfig(shortenBucket, time.Hour).WithSyncGapShorten(time.Second),
+		cachecloud.NewDistMemCacheConfig(revalidateBucket, time.Hour).WithSyncGapRevalidate(),
+		cachecloud.NewDistMemCacheConfig(foreverBucket, 0).WithSyncGapShorten(time.Second),
+	)
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	for _, bucket := range []cachecloud.BucketName{flushBucket, shortenBucket, revalidateBucket, foreverBucket} {
+		_ = cachecloud.PutCacheValue(bucket, cacheKeyTest, Model{Name: "acexy"})
+	}
+	transport.SimulateGap("test:dis-mem-sync-topic")
+
+	var value Model
+	fmt.Println(flushBucket, cachecloud.GetCacheValue(flushBucket, cacheKeyTest, &value))
+	fmt.Println(shortenBucket, cachecloud.GetCacheValue(shortenBucket, cacheKeyTest, &value))
+	time.Sleep(time.Second)
+	fmt.Println(shortenBucket, cachecloud.GetCacheValue(shortenBucket, cacheKeyTest, &value))
+	// 不过期的缓存同样被缩短
+	fmt.Println(foreverBucket, cachecloud.GetCacheValue(foreverBucket, cacheKeyTest, &value))
+	// 过时数据仍然返回 同时在后台刷新
+	_ = cachecloud.Cacheable[Model](revalidateBucket, cacheKeyTest, &value, func() (*Model, bool) {
+		return &Model{Name: "refreshed"}, true
+	})
+	fmt.Println(revalidateBucket, json.ToString(value))
+	time.Sleep(100 * time.Millisecond)
+	fmt.Println(revalidateBucket, cachecloud.GetCacheValue(revalidateBucket, cacheKeyTest, &value), json.ToString(value))
+}
+
+func TestDistMemSyncBatch(t *testing.T) {
+	transport := cachecloud.NewLoopbackTransport()
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", SyncTransport: transport},
+		cachecloud.NewDistMemCacheConfig(oneHourBucket, time.Hour).WithSyncBatch(200*time.Millisecond, 3),
+	)
+	_ = transport.Subscribe(context.Background(), "test:dis-mem-sync-topic", func(payload string) {
+		fmt.Println("peer received", payload)
+	})
+	// 达到批次大小由后台发布 重复的缓存key仅发布最后一次
+	for _, key := range []string{"a", "b", "a", "c", "d"} {
+		_ = cachecloud.EvictCache(oneHourBucket, cachecloud.CacheKey{KeyFormat: key})
+	}
+	fmt.Println("waiting window")
+	time.Sleep(300 * time.Millisecond)
+	// 清空事件丢弃此前暂存的变化 退出前发布暂存的事件
+	_ = cachecloud.EvictCache(oneHourBucket, cachecloud.CacheKey{KeyFormat: "e"})
+	_ = cachecloud.ClearBucket(context.Background(), oneHourBucket)
+	_ = cachecloud.PutCacheValue(oneHourBucket, cachecloud.CacheKey{KeyFormat: "f"}, Model{Name: "acexy"})
+	fmt.Println("shutdown", cachecloud.Shutdown(context.Background()))
+}
+
+func TestDistMemEvictFormat(t *testing.T) {
+	transport := cachecloud.NewLoopbackTransport()
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", SyncTransport: transport},
+		cachecloud.NewDistMemCacheConfig(oneHourBucket, time.Hour),
+	)
+	_ = transport.Subscribe(context.Background(), "test:dis-mem-sync-topic", func(payload string) {
+		fmt.Println("peer received", payload)
+	})
+	profileKey := cachecloud.CacheKey{KeyFormat: "user:%d:profile"}
+	ordersKey := cachecloud.CacheKey{KeyFormat: "user:%d:orders"}
+	for i := 1; i <= 3; i++ {
+		_ = cachecloud.PutCacheValue(oneHourBucket, profileKey, Model{Name: "profile"}, i)
+		_ = cachecloud.PutCacheValue(oneHourBucket, ordersKey, Model{Name: "orders"}, i)
+	}
+	fmt.Println(cachecloud.EvictFormat(context.Background(), oneHourBucket, profileKey))
+	var value Model
+	for i := 1; i <= 3; i++ {
+		fmt.Println(cachecloud.GetCacheValue(oneHourBucket, profileKey, &value, i), cachecloud.GetCacheValue(oneHourBucket, ordersKey, &value, i))
+	}
+}
+
+func TestDistMemStream(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", SyncTransport: cachecloud.NewRedisStreamTransport(cachecloud.RedisStreamOption{MaxLen: 1000})},
+		cachecloud.NewDistMemCacheConfig(oneHourBucket, time.Hour),
+	)
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				var value Model
+				_ = cachecloud.Cacheable[Model](oneHourBucket, cacheKeyTest, &value, func() (*Model, bool) {
+					return &Model{Name: "acexy", Age: time.Now().Second()}, true
+				})
+				fmt.Println(json.ToString(value))
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+
+	sys.ShutdownCallback(func() {
+		done <- true
+	})
+}
@@ -136,3 +136,18 @@ func TestLevel2Deleted(t *testing.T) {
 	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test%d"}
 	_ = cachecloud.EvictCache(level2Bucket, cacheKeyTest, 1)
 }
+
+func TestAutoEnableLevel2(t *testing.T) {
+	bucket := cachecloud.BucketName("auto")
+	cachecloud.Init(
+		cachecloud.Option{
+			ServiceName:           "test",
+			AutoEnable2LevelCache: true,
+		},
+		cachecloud.NewMemCacheConfig(bucket, time.Second*5),
+		cachecloud.NewRedisCacheConfig(bucket, time.Hour),
+	)
+	fmt.Println(cachecloud.PromotedLevel2Buckets())
+	fmt.Println(cachecloud.GetBucketByType(bucket, cachecloud.BucketTypeMem) == nil)
+	fmt.Println(cachecloud.GetBucketByType(bucket, cachecloud.BucketTypeLevel2) != nil)
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// isolatedEnv 子进程标记 子进程中直接运行指定的测试
+const isolatedEnv = "CACHECLOUD_ISOLATED_TEST"
+
+// TestMain cachecloud.Init 每个进程仅生效一次，同时运行的测试将共用首个测试的存储桶配置
+// 因此每个测试在独立的子进程中运行
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if os.Getenv(isolatedEnv) != "" || flagValue("test.list") != "" {
+		os.Exit(m.Run())
+	}
+	// -run 中的子测试部分原样传给子进程
+	run, subTests, _ := strings.Cut(flagValue("test.run"), "/")
+	if run == "" {
+		run = "."
+	}
+	names, err := listTests(run)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "list tests failed:", err)
+		os.Exit(1)
+	}
+	code := 0
+	for _, name := range names {
+		pattern := "^" + name + "$"
+		if subTests != "" {
+			pattern += "/" + subTests
+		}
+		cmd := exec.Command(os.Args[0], append(os.Args[1:], "-test.run="+pattern)...)
+		cmd.Env = append(os.Environ(), isolatedEnv+"=1")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err = cmd.Run(); err != nil {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// listTests 获取匹配的测试名称
+func listTests(run string) ([]string, error) {
+	output, err := exec.Command(os.Args[0], "-test.list="+run).Output()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); strings.HasPrefix(name, "Test") {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+func flagValue(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
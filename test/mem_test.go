@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -74,3 +75,108 @@ func TestBugFix(t *testing.T) {
 
 	cachecloud.GetBucket(BucketMem1Day)
 }
+
+func TestMemWithContext(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = cachecloud.PutCacheValueWithContext(ctx, oneHourBucket, cacheKeyTest, Model{Name: "acexy", Sex: 1, Age: 18})
+	var value Model
+	fmt.Println(cachecloud.GetCacheValueWithContext(ctx, oneHourBucket, cacheKeyTest, &value), json.ToString(value))
+
+	// 上下文取消后操作将直接返回
+	cancel()
+	var value1 Model
+	fmt.Println(cachecloud.GetCacheValueWithContext(ctx, oneHourBucket, cacheKeyTest, &value1), json.ToString(value1))
+}
+
+func TestMemWithExpire(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "token:%d"}
+	// 单条缓存1秒后过期
+	_ = cachecloud.PutCacheValueWithExpire(context.Background(), oneHourBucket, cacheKeyTest, "token-1", time.Second, 1)
+	_ = cachecloud.PutCacheValueWithExpireAt(context.Background(), oneHourBucket, cacheKeyTest, "token-2", time.Now().Add(time.Minute), 2)
+
+	var value1, value2 string
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value1, 1), value1)
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value2, 2), value2)
+
+	time.Sleep(time.Second * 2)
+	fmt.Println("等待2秒后继续获取")
+	var value3, value4 string
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value3, 1), value3)
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value4, 2), value4)
+}
+
+func TestMemClear(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test%d"}
+	_ = cachecloud.PutCacheValue(oneHourBucket, cacheKeyTest, "v1", 1)
+	_ = cachecloud.PutCacheValue(oneHourBucket, cacheKeyTest, "v2", 2)
+
+	// 清空存储桶后获取
+	fmt.Println(cachecloud.ClearBucket(context.Background(), oneHourBucket))
+	var value string
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value, 1))
+	fmt.Println(cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &value, 2))
+}
+
+func TestMemKeys(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+	profileKey := cachecloud.CacheKey{KeyFormat: "user:%d:profile"}
+	for i := 1; i <= 3; i++ {
+		_ = cachecloud.PutCacheValue(oneHourBucket, profileKey, Model{Name: "profile"}, i)
+	}
+	_ = cachecloud.PutCacheValue(oneHourBucket, cachecloud.CacheKey{KeyFormat: "order:1"}, Model{Name: "order"})
+
+	for key, err := range cachecloud.Keys(context.Background(), oneHourBucket) {
+		fmt.Println(key, err)
+	}
+	fmt.Println("匹配 user:[12]:*")
+	for key, err := range cachecloud.Keys(context.Background(), oneHourBucket, cachecloud.KeysOption{Pattern: "user:[12]:*"}) {
+		fmt.Println(key, err)
+	}
+}
+
+func TestMemEntryMeta(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	ctx := context.Background()
+	fmt.Println(cachecloud.Exists(ctx, oneHourBucket, cacheKeyTest))
+	_ = cachecloud.PutCacheValueWithExpire(ctx, oneHourBucket, cacheKeyTest, Model{Name: "acexy"}, time.Minute)
+	fmt.Println(cachecloud.Exists(ctx, oneHourBucket, cacheKeyTest))
+	fmt.Println(cachecloud.TTL(ctx, oneHourBucket, cacheKeyTest))
+
+	var value Model
+	meta, err := cachecloud.GetWithMeta(ctx, oneHourBucket, cacheKeyTest, &value)
+	fmt.Println(json.ToString(meta), meta.TTL(), err, json.ToString(value))
+}
+
+func TestMemSliding(t *testing.T) {
+	slidingBucket := cachecloud.BucketName("sliding")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(slidingBucket, time.Second).WithSlidingExpire(100*time.Millisecond))
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	ctx := context.Background()
+	_ = cachecloud.PutCacheValueWithContext(ctx, slidingBucket, cacheKeyTest, Model{Name: "acexy"})
+
+	// 持续访问的缓存不会过期
+	var value Model
+	for i := 0; i < 6; i++ {
+		time.Sleep(500 * time.Millisecond)
+		fmt.Println(cachecloud.GetCacheValueWithContext(ctx, slidingBucket, cacheKeyTest, &value), json.ToString(value))
+	}
+	time.Sleep(700 * time.Millisecond)
+	fmt.Println(cachecloud.TTL(ctx, slidingBucket, cacheKeyTest))
+	fmt.Println(cachecloud.Touch(ctx, slidingBucket, cacheKeyTest))
+	fmt.Println(cachecloud.TTL(ctx, slidingBucket, cacheKeyTest))
+	time.Sleep(1100 * time.Millisecond)
+	fmt.Println(cachecloud.Touch(ctx, slidingBucket, cacheKeyTest))
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -75,3 +76,28 @@ func TestRedis(t *testing.T) {
 	fmt.Println(json.ToString(value2))
 
 }
+
+func TestEvictByTag(t *testing.T) {
+	userBucket := cachecloud.BucketName("user")
+	orderBucket := cachecloud.BucketName("order")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test"},
+		cachecloud.NewRedisCacheConfig(userBucket, time.Hour),
+		cachecloud.NewLevel2CacheConfig(orderBucket, time.Minute, time.Hour),
+	)
+	ctx := context.Background()
+	profileKey := cachecloud.CacheKey{KeyFormat: "user:%d:profile"}
+	orderKey := cachecloud.CacheKey{KeyFormat: "order:%d"}
+	_ = cachecloud.PutCacheValueWithTags(ctx, userBucket, profileKey, Model{Name: "acexy"}, 0, []string{"user:42"}, 42)
+	_ = cachecloud.PutCacheValueWithTags(ctx, orderBucket, orderKey, Model{Name: "order"}, 0, []string{"user:42"}, 1001)
+	// Cacheable 重建缓存时附加标签
+	var value Model
+	_ = cachecloud.CacheableWithOption[Model](ctx, orderBucket, orderKey, &value, func() (*Model, bool) {
+		return &Model{Name: "order"}, true
+	}, cachecloud.CacheableOption{Tags: []string{"user:42"}}, 1002)
+
+	fmt.Println(cachecloud.EvictByTag(ctx, "user:42"))
+	fmt.Println(cachecloud.GetCacheValue(userBucket, profileKey, &value, 42))
+	fmt.Println(cachecloud.GetCacheValue(orderBucket, orderKey, &value, 1001))
+	fmt.Println(cachecloud.GetCacheValue(orderBucket, orderKey, &value, 1002))
+}
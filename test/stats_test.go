@@ -0,0 +1,47 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/acexy/golang-toolkit/util/json"
+	"github.com/golang-acexy/cloud-cache/cachecloud"
+)
+
+func TestStats(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "user:%d"}
+	var result int
+	_ = cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &result, 1)
+	_ = cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, func() (*int, bool) {
+		value := 1
+		return &value, true
+	}, 1)
+	_ = cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &result, 1)
+	_ = cachecloud.EvictCache(oneHourBucket, cacheKeyTest, 1)
+
+	stats, err := cachecloud.Stats(oneHourBucket)
+	fmt.Println(err)
+	fmt.Println(json.ToString(stats))
+	fmt.Println(len(cachecloud.AllStats()))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "test"}
+	_ = cachecloud.PutCacheValue(oneHourBucket, cacheKeyTest, 1)
+	var result int
+	_ = cachecloud.GetCacheValue(oneHourBucket, cacheKeyTest, &result)
+
+	recorder := httptest.NewRecorder()
+	cachecloud.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	fmt.Println(recorder.Header().Get("Content-Type"))
+	fmt.Println(recorder.Body.String())
+}
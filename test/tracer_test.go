@@ -0,0 +1,43 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-acexy/cloud-cache/cachecloud"
+)
+
+type printTracer struct {
+}
+
+func (p printTracer) Start(ctx context.Context, span cachecloud.SpanStart) (context.Context, cachecloud.Span) {
+	return ctx, printSpan{start: span, begin: time.Now()}
+}
+
+type printSpan struct {
+	start cachecloud.SpanStart
+	begin time.Time
+}
+
+func (p printSpan) End(end cachecloud.SpanEnd) {
+	fmt.Println(p.start.Operation, p.start.BucketName, p.start.BucketType, p.start.Key, end.Tier, end.Hit, end.Err, time.Since(p.begin))
+}
+
+func TestTracer(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(
+		cachecloud.Option{ServiceName: "test", Tracer: printTracer{}, TraceHashKey: true},
+		cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour),
+	)
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "user:%d"}
+	var result int
+	_ = cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, func() (*int, bool) {
+		value := 1
+		return &value, true
+	}, 1)
+	_ = cachecloud.Cacheable[int](oneHourBucket, cacheKeyTest, &result, nil, 1)
+	_ = cachecloud.EvictCache(oneHourBucket, cacheKeyTest, 1)
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/acexy/golang-toolkit/util/json"
+	"github.com/golang-acexy/cloud-cache/cachecloud"
+)
+
+func TestTypedBucket(t *testing.T) {
+	oneHourBucket := cachecloud.BucketName("1h")
+	cachecloud.Init(cachecloud.Option{ServiceName: "test"}, cachecloud.NewMemCacheConfig(oneHourBucket, time.Hour))
+	models := cachecloud.NewTypedBucket[Model](oneHourBucket)
+
+	cacheKeyTest := cachecloud.CacheKey{KeyFormat: "user:%d"}
+	_ = models.Put(cacheKeyTest, Model{Name: "acexy", Sex: 1, Age: 18}, 1)
+	value, err := models.Get(cacheKeyTest, 1)
+	fmt.Println(json.ToString(value), err)
+
+	// 未命中时调用loader
+	value, err = models.GetOrLoad(cacheKeyTest, func() (Model, bool) {
+		return Model{Name: "loaded", Age: 20}, true
+	}, 2)
+	fmt.Println(json.ToString(value), err)
+
+	keys := []cachecloud.BatchKey{
+		cachecloud.NewBatchKey(cacheKeyTest, 1),
+		cachecloud.NewBatchKey(cacheKeyTest, 2),
+		cachecloud.NewBatchKey(cacheKeyTest, 3),
+	}
+	values, hits, err := models.MGet(context.Background(), keys)
+	fmt.Println(hits, err, json.ToString(values))
+
+	_ = models.MEvict(context.Background(), keys)
+	value, err = models.Get(cacheKeyTest, 1)
+	fmt.Println(json.ToString(value), err)
+}